@@ -2,63 +2,372 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"github.com/ardanlabs/service/business/sys/auth"
-	"github.com/ardanlabs/service/internal/keystore"
-	"syscall"
-
-	"log"
+	"io/fs"
 	"net/http"
 	"os"
 	"os/signal"
-	"sync"
-
+	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/ardanlabs/service/app/services/sales-api"
+	"github.com/ardanlabs/service/business/core/apikey"
+	"github.com/ardanlabs/service/business/core/apikey/stores/apikeydb"
+	"github.com/ardanlabs/service/business/core/user"
+	"github.com/ardanlabs/service/business/core/user/stores/userdb"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/sys/auth/stores/refreshdb"
+	"github.com/ardanlabs/service/business/sys/auth/stores/revokedb"
+	"github.com/ardanlabs/service/internal/keystore"
+	"github.com/ardanlabs/service/internal/platform/conf"
+	"github.com/ardanlabs/service/internal/platform/logger"
+	"github.com/ardanlabs/service/internal/platform/tracing"
+	"github.com/ardanlabs/service/internal/platform/web"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
-/*
-Need to figure out timeouts for http service.
-Use env for all configuration values.
-*/
+// build is the git version of this program. It is set using build flags in
+// the makefile.
+var build = "develop"
 
-func init() {
-	log.SetFlags(log.LstdFlags | log.Lmicroseconds | log.Lshortfile)
+func main() {
+
+	// Construct the application logger.
+	log := logger.New(os.Stdout, "SALES-API", logger.LevelInfo, web.GetTraceID)
+
+	// Perform the startup and shutdown sequence.
+	if err := run(log); err != nil {
+		log.Error(context.Background(), "startup", "error", err)
+		os.Exit(1)
+	}
 }
 
-func main() {
+// config is the configuration of the service. The conf tags declare the
+// defaults and help text of each setting.
+type config struct {
+	conf.Version
+	Web struct {
+		ReadTimeout     time.Duration `conf:"default:5s"`
+		WriteTimeout    time.Duration `conf:"default:10s"`
+		IdleTimeout     time.Duration `conf:"default:120s"`
+		ShutdownTimeout time.Duration `conf:"default:20s"`
+		APIHost         string        `conf:"default:0.0.0.0:3000"`
+		DebugHost       string        `conf:"default:0.0.0.0:4000"`
+		DebugTimeout    time.Duration `conf:"default:60s,help:write timeout of the debug port, long enough for a 30s cpu profile"`
+		CheckTimeout    time.Duration `conf:"default:1s"`
+	}
+	Auth struct {
+		KeysFolder    string        `conf:"default:zarf/keys/"`
+		ActiveKID     string        `conf:"default:54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"`
+		ActiveKIDFile string        `conf:"default:zarf/keys/active.kid,help:overrides the active kid when the file exists"`
+		RetireAfter   time.Duration `conf:"default:24h,help:how long a rotated out key is still accepted"`
+		Issuer        string        `conf:"default:service project"`
+		Audience      string        `conf:"default:sales-api"`
+		Leeway        time.Duration `conf:"default:30s,help:allowed clock skew when checking token times"`
+		MaxTokenAge   time.Duration `conf:"help:reject tokens issued longer ago, regardless of expiry"`
+		TokenExpiry   time.Duration `conf:"default:15m,help:lifetime of an access token"`
+		RefreshExpiry time.Duration `conf:"default:720h,help:how long a refresh token can be used"`
+		RefreshPath   string        `conf:"default:zarf/db/refresh.json,help:empty keeps refresh tokens in memory"`
+		JWKSMaxAge    time.Duration `conf:"default:5m"`
+		RemoteJWKSURL string        `conf:"help:accept tokens signed by the keys published at this url"`
+		RemoteJWKSTTL time.Duration `conf:"default:1h"`
+		RemoteJWKSMin time.Duration `conf:"default:30s,help:minimum time between fetches for an unknown kid"`
+		RemoteIssuer  string        `conf:"help:issuer of the tokens signed by the remote jwks keys"`
+		RevokedPath   string        `conf:"default:zarf/db/revoked.json,help:empty keeps revoked tokens in memory"`
+		PolicyFile    string        `conf:"default:zarf/config/policy.json,help:role to permission table, empty uses the built in policy"`
+		PruneInterval time.Duration `conf:"default:1h,help:how often expired revocations and refresh tokens are removed, 0 never removes them"`
+	}
+	DB struct {
+		Path        string `conf:"default:zarf/db/users.json"`
+		APIKeysPath string `conf:"default:zarf/db/apikeys.json"`
+	}
+	Trace struct {
+		Exporter     string  `conf:"default:file,help:stdout|file|otlp|none"`
+		FilePath     string  `conf:"default:zarf/traces/spans.json"`
+		OTLPEndpoint string  `conf:"default:http://localhost:4318"`
+		ServiceName  string  `conf:"default:sales-api"`
+		Probability  float64 `conf:"default:0.05"`
+	}
+}
 
-	// ============================================================
+func run(log *logger.Logger) error {
+	ctx := context.Background()
+
+	// =========================================================================
 	// Configuration
-	KeysFolder := "zarf/keys"
-	ActiveKID := "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"
-	readTimeout := 5 * time.Second
-	writeTimeout := 10 * time.Second
-	shutdownTimeout := 5 * time.Second
-	host := os.Getenv("HOST")
-	if host == "" {
-		host = ":3000"
+
+	cfg := config{
+		Version: conf.Version{
+			Build: build,
+			Desc:  "sales-api service",
+		},
+	}
+
+	const prefix = "SALES"
+	help, err := conf.Parse(prefix, &cfg)
+	if err != nil {
+		if errors.Is(err, conf.ErrHelpWanted) {
+			fmt.Println(help)
+			return nil
+		}
+		return fmt.Errorf("parsing config: %w", err)
 	}
+	if cfg.Auth.PruneInterval < 0 {
+		return fmt.Errorf("prune interval %v must not be negative", cfg.Auth.PruneInterval)
+	}
+
+	// =========================================================================
+	// App Starting
+
+	log.Info(ctx, "starting service", "version", build)
 
+	out, err := conf.String(&cfg)
+	if err != nil {
+		return fmt.Errorf("generating config for output: %w", err)
+	}
+	log.Info(ctx, "startup", "config", out)
+
+	// =========================================================================
+	// Start Tracing Support
+
+	log.Info(ctx, "startup", "status", "initializing tracing support", "exporter", cfg.Trace.Exporter)
+
+	var exporter sdktrace.SpanExporter
+	switch cfg.Trace.Exporter {
+	case "stdout":
+		exporter, err = tracing.NewWriterExporter(os.Stdout)
+
+	case "file":
+		if err := os.MkdirAll(filepath.Dir(cfg.Trace.FilePath), 0755); err != nil {
+			return fmt.Errorf("creating trace folder: %w", err)
+		}
+
+		f, err := os.OpenFile(cfg.Trace.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("opening trace file: %w", err)
+		}
+		defer f.Close()
+
+		exporter, err = tracing.NewWriterExporter(f)
+		if err != nil {
+			return fmt.Errorf("constructing trace exporter: %w", err)
+		}
+
+	case "otlp":
+		exporter, err = tracing.NewOTLPExporter(ctx, cfg.Trace.OTLPEndpoint)
+
+	case "none":
+
+	default:
+		return fmt.Errorf("unknown trace exporter %q", cfg.Trace.Exporter)
+	}
+	if err != nil {
+		return fmt.Errorf("constructing trace exporter: %w", err)
+	}
+
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.Error(ctx, "tracing", "status", "exporting spans", "error", err)
+	}))
+
+	// The provider is shut down before the trace file is closed so the
+	// spans still being batched are written.
+	traceProvider := tracing.NewProvider(tracing.Config{
+		ServiceName: cfg.Trace.ServiceName,
+		Probability: cfg.Trace.Probability,
+		Exporter:    exporter,
+	})
+	defer traceProvider.Shutdown(context.Background())
+
+	// =========================================================================
+	// Database Support
+
+	log.Info(ctx, "startup", "status", "initializing database support", "path", cfg.DB.Path)
+
+	usrStore, err := userdb.NewStore(cfg.DB.Path)
+	if err != nil {
+		return fmt.Errorf("opening user store: %w", err)
+	}
+
+	// Routes check permissions, the policy decides which roles grant them
+	// and so which roles users can hold.
+	policy := auth.DefaultPolicy()
+	if cfg.Auth.PolicyFile != "" {
+		if policy, err = auth.LoadPolicyFile(cfg.Auth.PolicyFile); err != nil {
+			return fmt.Errorf("loading policy: %w", err)
+		}
+	}
+
+	usrCore := user.NewCore(usrStore, policy)
 
 	// =========================================================================
 	// Initialize authentication support
 
-	//log.Println("startup", "status", "initializing authentication support")
+	log.Info(ctx, "startup", "status", "initializing authentication support")
 
-	//Construct a key store based on the key files stored in
-	//the specified directory.
-	ks, err:= keystore.NewFS(os.DirFS(KeysFolder))
+	// Construct a key store based on the key files stored in
+	// the specified directory.
+	ks, err := keystore.NewFS(os.DirFS(cfg.Auth.KeysFolder))
 	if err != nil {
-		fmt.Errorf("constructing auth: %w", err)
+		return fmt.Errorf("reading keys: %w", err)
 	}
 
-	auth, err := auth.New(ActiveKID, ks)
+	activeKID, err := readActiveKID(cfg.Auth.ActiveKIDFile, cfg.Auth.ActiveKID)
 	if err != nil {
-		fmt.Errorf("constructing auth: %w", err)
+		return fmt.Errorf("reading active kid: %w", err)
 	}
 
+	if err := ks.Activate(activeKID); err != nil {
+		return fmt.Errorf("activating key %q: %w", activeKID, err)
+	}
+
+	// Tokens from an identity provider are verified only with the keys it
+	// publishes, our own keys only verify the tokens we issue.
+	authOpts := []auth.Option{
+		auth.WithIssuer(cfg.Auth.Issuer),
+	}
+	if cfg.Auth.RemoteJWKSURL != "" {
+		if cfg.Auth.RemoteIssuer == "" {
+			return errors.New("remote jwks url requires a remote issuer")
+		}
+		remote := keystore.NewRemote(keystore.RemoteConfig{
+			URL:                cfg.Auth.RemoteJWKSURL,
+			TTL:                cfg.Auth.RemoteJWKSTTL,
+			MinRefreshInterval: cfg.Auth.RemoteJWKSMin,
+		})
+		authOpts = append(authOpts, auth.WithIssuerKeys(cfg.Auth.RemoteIssuer, remote))
+	}
+
+	// Revoked tokens are rejected until they expire, then pruned.
+	revoked, err := revokedb.NewStore(cfg.Auth.RevokedPath)
+	if err != nil {
+		return fmt.Errorf("opening revocation store: %w", err)
+	}
+
+	// Refresh tokens are kept server side so they can be rotated on each use.
+	refreshes, err := refreshdb.NewStore(cfg.Auth.RefreshPath)
+	if err != nil {
+		return fmt.Errorf("opening refresh store: %w", err)
+	}
+
+	// Pruning stops once run returns.
+	stopPrune := make(chan struct{})
+	defer close(stopPrune)
+
+	if cfg.Auth.PruneInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(cfg.Auth.PruneInterval)
+			defer ticker.Stop()
+
+			for {
+				var now time.Time
+				select {
+				case now = <-ticker.C:
+				case <-stopPrune:
+					return
+				}
+
+				if n, err := revoked.Prune(ctx, now); err != nil {
+					log.Error(ctx, "revocation", "status", "pruning failed", "error", err)
+				} else if n > 0 {
+					log.Info(ctx, "revocation", "status", "pruned expired entries", "count", n)
+				}
+
+				if n, err := refreshes.Prune(ctx, now); err != nil {
+					log.Error(ctx, "refresh", "status", "pruning failed", "error", err)
+				} else if n > 0 {
+					log.Info(ctx, "refresh", "status", "pruned expired tokens", "count", n)
+				}
+			}
+		}()
+	}
+
+	// API keys are accepted as an alternative to tokens.
+	apiKeyStore, err := apikeydb.NewStore(cfg.DB.APIKeysPath)
+	if err != nil {
+		return fmt.Errorf("opening api key store: %w", err)
+	}
+
+	authOpts = append(authOpts,
+		auth.WithAudiences(cfg.Auth.Audience),
+		auth.WithLeeway(cfg.Auth.Leeway),
+		auth.WithMaxAge(cfg.Auth.MaxTokenAge),
+		auth.WithRequiredClaims(auth.ClaimSubject, auth.ClaimExpiresAt),
+		auth.WithRevocationStore(revoked),
+		auth.WithRefreshTokens(refreshes, cfg.Auth.RefreshExpiry),
+		auth.WithUserLookup(usrCore),
+		auth.WithPolicy(policy),
+		auth.WithAPIKeys(apikey.NewCore(apiKeyStore, usrCore, policy)),
+	)
+
+	auth, err := auth.New(activeKID, ks, authOpts...)
+	if err != nil {
+		return fmt.Errorf("constructing auth: %w", err)
+	}
+
+	// Keys are rotated without a restart by adding the new key file to the
+	// keys folder, writing its kid to the active kid file and sending SIGHUP.
+	// The previously active key is still accepted until it retires.
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+
+	go func() {
+		for range reload {
+			if err := rotateKeys(ks, cfg.Auth.KeysFolder, cfg.Auth.ActiveKIDFile, cfg.Auth.ActiveKID, cfg.Auth.RetireAfter); err != nil {
+				log.Error(ctx, "key rotation", "status", "failed", "error", err)
+				continue
+			}
+
+			kid, _ := ks.ActiveKID()
+			log.Info(ctx, "key rotation", "status", "keys reloaded", "activekid", kid)
+		}
+	}()
+
+	// =========================================================================
+	// Start Debug Service
+
+	log.Info(ctx, "startup", "status", "debug router started", "host", cfg.Web.DebugHost)
+
+	// Readiness checks are run by /debug/readiness on every probe.
+	checks := map[string]sales_api.ReadinessCheck{
+		"keystore": func(ctx context.Context) error {
+			kid, err := ks.ActiveKID()
+			if err != nil {
+				return err
+			}
+			_, err = ks.PrivateKeyPEM(kid)
+			return err
+		},
+		"db": usrStore.Ping,
+	}
+
+	debugMux := sales_api.DebugMux(sales_api.DebugMuxConfig{
+		Build:        build,
+		Log:          log,
+		Checks:       checks,
+		CheckTimeout: cfg.Web.CheckTimeout,
+	})
+
+	debug := http.Server{
+		Addr:           cfg.Web.DebugHost,
+		Handler:        debugMux,
+		ReadTimeout:    cfg.Web.ReadTimeout,
+		WriteTimeout:   cfg.Web.DebugTimeout,
+		IdleTimeout:    cfg.Web.IdleTimeout,
+		MaxHeaderBytes: 1 << 20,
+	}
+
+	// Start the service listening for debug requests. It's shut down after
+	// the api so the probes keep answering while requests drain.
+	go func() {
+		if err := debug.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error(ctx, "shutdown", "status", "debug router closed", "host", cfg.Web.DebugHost, "error", err)
+		}
+	}()
 
 	// ============================================================
 	// Start Service
@@ -67,24 +376,36 @@ func main() {
 	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
 
 	apiMux := sales_api.APIMux(sales_api.APIMuxConfig{
-		Shutdown: shutdown,
-		Auth: auth,
+		Shutdown:      shutdown,
+		Log:           log,
+		Auth:          auth,
+		Policy:        policy,
+		UserStore:     usrStore,
+		APIKeyStore:   apiKeyStore,
+		TokenIssuer:   cfg.Auth.Issuer,
+		TokenAudience: cfg.Auth.Audience,
+		TokenExpiry:   cfg.Auth.TokenExpiry,
+		JWKSMaxAge:    cfg.Auth.JWKSMaxAge,
+		Tracer:        traceProvider.Tracer(cfg.Trace.ServiceName),
 	})
 
 	server := http.Server{
-		Addr:           host,
+		Addr:           cfg.Web.APIHost,
 		Handler:        apiMux,
-		ReadTimeout:    readTimeout,
-		WriteTimeout:   writeTimeout,
+		ReadTimeout:    cfg.Web.ReadTimeout,
+		WriteTimeout:   cfg.Web.WriteTimeout,
+		IdleTimeout:    cfg.Web.IdleTimeout,
 		MaxHeaderBytes: 1 << 20,
 	}
+
+	// Make a channel to listen for errors coming from the listener. Use a
+	// buffered channel so the goroutine can exit if we don't collect this error.
+	serverErrors := make(chan error, 1)
+
 	// Starting the service, listening for requests.
-	var wg sync.WaitGroup
-	wg.Add(1)
 	go func() {
-		log.Printf("startup : Listening %s", host)
-		log.Printf("shutdown : Listener closed : %v", server.ListenAndServe())
-		wg.Done()
+		log.Info(ctx, "startup", "status", "api router started", "host", server.Addr)
+		serverErrors <- server.ListenAndServe()
 	}()
 
 	// ============================================================
@@ -92,24 +413,73 @@ func main() {
 
 	// Blocking main and waiting for shutdown.
 	select {
-	case sig := <- shutdown:
-		log.Println("stop", sig)
+	case err := <-serverErrors:
+		return fmt.Errorf("server error: %w", err)
+
+	case sig := <-shutdown:
+		log.Info(ctx, "shutdown", "status", "shutdown started", "signal", sig)
+		defer log.Info(ctx, "shutdown", "status", "shutdown complete", "signal", sig)
+
 		// Create context for Shutdown call.
-		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		ctx, cancel := context.WithTimeout(ctx, cfg.Web.ShutdownTimeout)
 		defer cancel()
 
+		// The server doesn't track upgraded connections, so WebSockets get
+		// their close frame first.
+		if err := apiMux.CloseWebSockets(ctx); err != nil {
+			log.Error(ctx, "shutdown", "status", "websockets dropped", "error", err)
+		}
+
 		// Asking listener to shutdown and load shed.
 		if err := server.Shutdown(ctx); err != nil {
-		log.Printf("shutdown : Graceful shutdown did not complete in %v : %v", shutdownTimeout, err)
+			server.Close()
+			debug.Close()
+			return fmt.Errorf("could not stop server gracefully: %w", err)
+		}
 
-		if err := server.Close(); err != nil {
-		log.Printf("shutdown : Error killing server : %v", err)
+		if err := debug.Shutdown(ctx); err != nil {
+			debug.Close()
+			return fmt.Errorf("could not stop debug server gracefully: %w", err)
 		}
 	}
+
+	return nil
 }
 
-	// Waiting for service to complete that load shedding.
-	wg.Wait()
-	log.Println("main : Completed")
+// readActiveKID returns the kid stored in the file, or the default kid when
+// the file doesn't exist.
+func readActiveKID(file string, defaultKID string) (string, error) {
+	data, err := os.ReadFile(file)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return defaultKID, nil
+	case err != nil:
+		return "", err
+	}
+
+	kid := strings.TrimSpace(string(data))
+	if kid == "" {
+		return "", fmt.Errorf("active kid file %q is empty", file)
+	}
+
+	return kid, nil
 }
 
+// rotateKeys loads new keys from the keys folder and activates the kid from
+// the active kid file. The previously active key retires after the delay.
+func rotateKeys(ks *keystore.KeyStore, folder string, file string, defaultKID string, retireAfter time.Duration) error {
+	if _, err := ks.LoadFS(os.DirFS(folder)); err != nil {
+		return fmt.Errorf("loading keys: %w", err)
+	}
+
+	kid, err := readActiveKID(file, defaultKID)
+	if err != nil {
+		return fmt.Errorf("reading active kid: %w", err)
+	}
+
+	if err := ks.Rotate(kid, time.Now().Add(retireAfter)); err != nil {
+		return fmt.Errorf("activating key %q: %w", kid, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/internal/platform/conf"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_Config(t *testing.T) {
+	t.Log("Given the need to start the service with its configuration.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen parsing the defaults.", testID)
+		{
+			setArgs(t)
+
+			var cfg config
+			if _, err := conf.Parse("SALES", &cfg); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to parse the config : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to parse the config.", success, testID)
+
+			if cfg.Auth.TokenExpiry != 15*time.Minute || cfg.Auth.PolicyFile != "zarf/config/policy.json" {
+				t.Fatalf("\t%s\tTest %d:\tShould set the defaults : %+v.", failed, testID, cfg.Auth)
+			}
+			t.Logf("\t%s\tTest %d:\tShould set the defaults.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen asking for help.", testID)
+		{
+			setArgs(t, "--help")
+
+			var cfg config
+			help, err := conf.Parse("SALES", &cfg)
+			if !errors.Is(err, conf.ErrHelpWanted) {
+				t.Fatalf("\t%s\tTest %d:\tShould get the help text : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the help text.", success, testID)
+
+			for _, text := range helpTexts(reflect.TypeOf(cfg)) {
+				if !strings.Contains(help, text) {
+					t.Fatalf("\t%s\tTest %d:\tShould show every help text : missing %q.", failed, testID, text)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould show every help text.", success, testID)
+		}
+	}
+}
+
+// setArgs replaces the command line arguments for the life of the test.
+func setArgs(t *testing.T, args ...string) {
+	orig := os.Args
+	os.Args = append([]string{"sales-api"}, args...)
+	t.Cleanup(func() { os.Args = orig })
+}
+
+// helpTexts returns the help text of every field in the struct type, read
+// straight from the tags.
+func helpTexts(typ reflect.Type) []string {
+	var texts []string
+	for i := 0; i < typ.NumField(); i++ {
+		fld := typ.Field(i)
+		if fld.Type.Kind() == reflect.Struct && fld.Type.PkgPath() == "" {
+			texts = append(texts, helpTexts(fld.Type)...)
+			continue
+		}
+
+		if _, text, ok := strings.Cut(fld.Tag.Get("conf"), "help:"); ok {
+			texts = append(texts, text)
+		}
+	}
+	return texts
+}
@@ -0,0 +1,132 @@
+package sales_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ardanlabs/service/business/core/apikey"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/internal/platform/web"
+)
+
+// APIKey represents the APIKey API method handler set.
+type APIKey struct {
+	Core *apikey.Core
+	Auth *auth.Auth
+}
+
+// createdAPIKey is the document returned when a key is created. It is the
+// only time the key itself is returned.
+type createdAPIKey struct {
+	apikey.APIKey
+	Key string `json:"key"`
+}
+
+// Create generates a new api key owned by the authenticated user. The key
+// can only be scoped to roles the user holds.
+func (k *APIKey) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var nk apikey.NewAPIKey
+	if err := web.Decode(r, &nk); err != nil {
+		return err
+	}
+
+	claims := auth.GetClaims(ctx)
+
+	key, secret, err := k.Core.Create(ctx, claims, nk, web.GetTime(ctx))
+	if err != nil {
+		if errors.Is(err, auth.ErrForbidden) {
+			return validate.NewRequestError(err, http.StatusForbidden)
+		}
+		return fmt.Errorf("create: %w", err)
+	}
+
+	return web.Respond(ctx, w, createdAPIKey{APIKey: key, Key: secret}, http.StatusCreated)
+}
+
+// apiKeyQuery declares the paging, ordering and filtering the List endpoint
+// supports.
+var apiKeyQuery = web.QueryConfig{
+	OrderFields: map[string]string{
+		"id":          apikey.OrderByID,
+		"name":        apikey.OrderByName,
+		"dateCreated": apikey.OrderByDateCreated,
+	},
+	DefaultOrder: web.OrderBy{Field: apikey.OrderByDateCreated, Direction: web.ASC},
+	Filters:      []string{"userID"},
+}
+
+// List returns a page of the api keys of the authenticated user, revoked
+// keys included. The keys of another user can be listed with the userID
+// filter by users who may manage the keys of every user.
+func (k *APIKey) List(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	q, err := web.ParseQuery(r, apiKeyQuery)
+	if err != nil {
+		return err
+	}
+
+	claims := auth.GetClaims(ctx)
+
+	userID, exists := q.Filters["userID"]
+	if !exists {
+		userID = claims.Subject
+	}
+
+	if err := k.Auth.AuthorizeOwner(claims, userID, auth.PermAPIKeysAdmin); err != nil {
+		return validate.NewRequestError(
+			fmt.Errorf("you are not authorized for that action: %w", err),
+			http.StatusForbidden,
+		)
+	}
+
+	filter := apikey.QueryFilter{UserID: &userID}
+	orderBy := apikey.OrderBy{
+		Field:      q.OrderBy.Field,
+		Descending: q.OrderBy.Direction == web.DESC,
+	}
+
+	keys, err := k.Core.Query(ctx, filter, orderBy, q.Page, q.RowsPerPage)
+	if err != nil {
+		return fmt.Errorf("unable to query for api keys: %w", err)
+	}
+
+	total, err := k.Core.Count(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("unable to count api keys: %w", err)
+	}
+
+	return web.Respond(ctx, w, web.NewPageDocument(keys, total, q), http.StatusOK)
+}
+
+// Revoke stops an api key from being used. Only the owner of the key may
+// revoke it, unless the user may manage the keys of every user.
+func (k *APIKey) Revoke(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id := web.Param(r, "id")
+
+	key, err := k.Core.QueryByID(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, apikey.ErrInvalidID):
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, apikey.ErrNotFound):
+			return validate.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	if err := k.Auth.AuthorizeOwner(auth.GetClaims(ctx), key.UserID, auth.PermAPIKeysAdmin); err != nil {
+		return validate.NewRequestError(
+			fmt.Errorf("you are not authorized for that action: %w", err),
+			http.StatusForbidden,
+		)
+	}
+
+	if _, err := k.Core.Revoke(ctx, id, web.GetTime(ctx)); err != nil {
+		return fmt.Errorf("ID[%s]: %w", id, err)
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
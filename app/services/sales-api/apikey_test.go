@@ -0,0 +1,232 @@
+package sales_api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/app/services/sales-api"
+	"github.com/ardanlabs/service/business/core/apikey"
+	"github.com/ardanlabs/service/business/core/apikey/stores/apikeydb"
+	"github.com/ardanlabs/service/business/core/user"
+	"github.com/ardanlabs/service/business/core/user/stores/userdb"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/internal/platform/logger"
+	"github.com/ardanlabs/service/internal/platform/web"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func Test_APIKeys(t *testing.T) {
+	t.Log("Given the need to manage api keys over the API.")
+
+	policy, err := auth.NewPolicy(map[string][]string{
+		auth.RoleAdmin: {"*"},
+		auth.RoleUser:  {auth.PermAPIKeysRead, auth.PermAPIKeysWrite},
+	})
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to build the policy : %v.", failed, err)
+	}
+
+	usrStore, err := userdb.NewStore("")
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to open the user store : %v.", failed, err)
+	}
+	keyStore, err := apikeydb.NewStore("")
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to open the api key store : %v.", failed, err)
+	}
+
+	usrCore := user.NewCore(usrStore, policy)
+	a := newAuth(t, auth.WithPolicy(policy), auth.WithAPIKeys(apikey.NewCore(keyStore, usrCore, policy)))
+
+	app := sales_api.APIMux(sales_api.APIMuxConfig{
+		Shutdown:    make(chan os.Signal, 1),
+		Log:         logger.New(io.Discard, "TEST", logger.LevelInfo, web.GetTraceID),
+		Auth:        a,
+		Policy:      policy,
+		UserStore:   usrStore,
+		APIKeyStore: keyStore,
+	})
+
+	alice := newUser(t, usrCore, a, "alice@example.com", auth.RoleUser)
+	bob := newUser(t, usrCore, a, "bob@example.com", auth.RoleUser)
+	admin := newUser(t, usrCore, a, "admin@example.com", auth.RoleAdmin)
+
+	var aliceKey, bobKey createdKey
+
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen creating api keys.", testID)
+		{
+			w := send(t, app, http.MethodPost, "/v1/apikeys", alice.token, `{"name":"nightly batch","roles":["USER"]}`)
+			if aliceKey = decode[createdKey](t, w); w.Code != http.StatusCreated || aliceKey.Key == "" || aliceKey.UserID != alice.id {
+				t.Fatalf("\t%s\tTest %d:\tShould create a key owned by the user : got %d : %s.", failed, testID, w.Code, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould create a key owned by the user.", success, testID)
+
+			w = send(t, app, http.MethodPost, "/v1/apikeys", bob.token, `{"name":"partner","roles":["USER"]}`)
+			if bobKey = decode[createdKey](t, w); w.Code != http.StatusCreated || bobKey.UserID != bob.id {
+				t.Fatalf("\t%s\tTest %d:\tShould create a key for another user : got %d : %s.", failed, testID, w.Code, w.Body.String())
+			}
+
+			w = send(t, app, http.MethodPost, "/v1/apikeys", alice.token, `{"name":"escalate","roles":["ADMIN"]}`)
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT create a key with a role the user lacks : got %d : %s.", failed, testID, w.Code, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT create a key with a role the user lacks.", success, testID)
+
+			w = send(t, app, http.MethodPost, "/v1/apikeys", alice.token, `{"name":"unknown","roles":["AUDITOR"]}`)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT create a key with an undefined role : got %d : %s.", failed, testID, w.Code, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT create a key with an undefined role.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen listing api keys.", testID)
+		{
+			var page web.PageDocument[apikey.APIKey]
+
+			w := send(t, app, http.MethodGet, "/v1/apikeys", alice.token, "")
+			if page = decode[web.PageDocument[apikey.APIKey]](t, w); w.Code != http.StatusOK || page.Total != 1 || len(page.Items) != 1 || page.Items[0].ID != aliceKey.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould list only the keys of the user : got %d : %s.", failed, testID, w.Code, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould list only the keys of the user.", success, testID)
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/apikeys", nil)
+			req.Header.Set("X-API-Key", aliceKey.Key)
+			w = httptest.NewRecorder()
+			app.ServeHTTP(w, req)
+			if page = decode[web.PageDocument[apikey.APIKey]](t, w); w.Code != http.StatusOK || page.Total != 1 || page.Items[0].ID != aliceKey.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould list the keys of the user authenticated by a key : got %d : %s.", failed, testID, w.Code, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould list the keys of the user authenticated by a key.", success, testID)
+
+			w = send(t, app, http.MethodGet, "/v1/apikeys?userID="+bob.id, alice.token, "")
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT list the keys of another user : got %d : %s.", failed, testID, w.Code, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT list the keys of another user.", success, testID)
+
+			w = send(t, app, http.MethodGet, "/v1/apikeys?userID="+bob.id, admin.token, "")
+			if page = decode[web.PageDocument[apikey.APIKey]](t, w); w.Code != http.StatusOK || page.Total != 1 || page.Items[0].ID != bobKey.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould let an admin list the keys of another user : got %d : %s.", failed, testID, w.Code, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould let an admin list the keys of another user.", success, testID)
+
+			w = send(t, app, http.MethodGet, "/v1/apikeys?rows=0", alice.token, "")
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould validate the paging : got %d : %s.", failed, testID, w.Code, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould validate the paging.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen revoking api keys.", testID)
+		{
+			w := send(t, app, http.MethodDelete, "/v1/apikeys/"+bobKey.ID, alice.token, "")
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT revoke the key of another user : got %d : %s.", failed, testID, w.Code, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT revoke the key of another user.", success, testID)
+
+			w = send(t, app, http.MethodDelete, "/v1/apikeys/5cf37266-3473-4006-984f-9325122678b7", alice.token, "")
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT revoke an unknown key : got %d : %s.", failed, testID, w.Code, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT revoke an unknown key.", success, testID)
+
+			w = send(t, app, http.MethodDelete, "/v1/apikeys/"+aliceKey.ID, alice.token, "")
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould revoke the key of the user : got %d : %s.", failed, testID, w.Code, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould revoke the key of the user.", success, testID)
+
+			w = send(t, app, http.MethodDelete, "/v1/apikeys/"+bobKey.ID, admin.token, "")
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould let an admin revoke the key of another user : got %d : %s.", failed, testID, w.Code, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould let an admin revoke the key of another user.", success, testID)
+
+			var page web.PageDocument[apikey.APIKey]
+			w = send(t, app, http.MethodGet, "/v1/apikeys", bob.token, "")
+			if page = decode[web.PageDocument[apikey.APIKey]](t, w); w.Code != http.StatusOK || page.Total != 1 || page.Items[0].DateRevoked == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould list the revoked key : got %d : %s.", failed, testID, w.Code, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould list the revoked key.", success, testID)
+		}
+	}
+}
+
+// createdKey is the document returned when a key is created.
+type createdKey struct {
+	apikey.APIKey
+	Key string `json:"key"`
+}
+
+// testUser is a user along with a token to call the API as that user.
+type testUser struct {
+	id    string
+	token string
+}
+
+// newUser creates the user and a token for it.
+func newUser(t *testing.T, core *user.Core, a *auth.Auth, email string, roles ...string) testUser {
+	nu := user.NewUser{
+		Name:            email,
+		Email:           email,
+		Roles:           roles,
+		Password:        "gophers",
+		PasswordConfirm: "gophers",
+	}
+
+	usr, err := core.Create(context.Background(), nu, time.Now())
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create user %s : %v.", failed, email, err)
+	}
+
+	claims := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   usr.ID,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+		Roles: roles,
+	}
+
+	token, err := a.GenerateToken(claims)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to generate a token for %s : %v.", failed, email, err)
+	}
+
+	return testUser{id: usr.ID, token: token}
+}
+
+// send makes the request with the token and returns the response.
+func send(t *testing.T, app http.Handler, method string, target string, token string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+	r.Header.Set("Authorization", "Bearer "+token)
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+
+	return w
+}
+
+// decode reads the JSON response.
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	var v T
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("\t%s\tShould be able to decode the response : %v : %s.", failed, err, w.Body.String())
+	}
+	return v
+}
@@ -0,0 +1,155 @@
+package sales_api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ardanlabs/service/internal/platform/logger"
+)
+
+// ReadinessCheck reports if a dependency of the service is ready to be used.
+type ReadinessCheck func(ctx context.Context) error
+
+// Check represents the health check handler set.
+type Check struct {
+	Build   string
+	Log     *logger.Logger
+	Checks  map[string]ReadinessCheck
+	Timeout time.Duration
+}
+
+// Readiness checks if the service's dependencies are ready and if not will
+// return a 503 status. Every check is run concurrently and must complete
+// within the configured timeout.
+func (c Check) Readiness(w http.ResponseWriter, r *http.Request) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	names := make([]string, 0, len(c.Checks))
+	for name := range c.Checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]error, len(names))
+	var wg sync.WaitGroup
+	wg.Add(len(names))
+	for i, name := range names {
+		go func(i int, check ReadinessCheck) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, check)
+		}(i, c.Checks[name])
+	}
+	wg.Wait()
+
+	data := struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}{
+		Status: "ok",
+		Checks: make(map[string]string, len(names)),
+	}
+	statusCode := http.StatusOK
+
+	for i, name := range names {
+		if err := results[i]; err != nil {
+			c.Log.Error(ctx, "readiness failure", "check", name, "error", err)
+			data.Checks[name] = err.Error()
+			data.Status = "not ready"
+			statusCode = http.StatusServiceUnavailable
+			continue
+		}
+		data.Checks[name] = "ok"
+	}
+
+	if err := response(w, statusCode, data); err != nil {
+		c.Log.Error(ctx, "readiness", "error", err)
+	}
+}
+
+// Liveness returns simple status info if the service is alive. If the
+// app is deployed to a Kubernetes cluster, it will also return pod, node, and
+// namespace details via the Downward API. The Kubernetes environment variables
+// need to be set within your Pod/Deployment manifest.
+func (c Check) Liveness(w http.ResponseWriter, r *http.Request) {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unavailable"
+	}
+
+	data := struct {
+		Status     string `json:"status,omitempty"`
+		Build      string `json:"build,omitempty"`
+		Host       string `json:"host,omitempty"`
+		Pod        string `json:"pod,omitempty"`
+		PodIP      string `json:"podIP,omitempty"`
+		Node       string `json:"node,omitempty"`
+		Namespace  string `json:"namespace,omitempty"`
+		GOMAXPROCS int    `json:"GOMAXPROCS,omitempty"`
+		Goroutines int    `json:"goroutines,omitempty"`
+	}{
+		Status:     "up",
+		Build:      c.Build,
+		Host:       host,
+		Pod:        os.Getenv("KUBERNETES_PODNAME"),
+		PodIP:      os.Getenv("KUBERNETES_NAMESPACE_POD_IP"),
+		Node:       os.Getenv("KUBERNETES_NODENAME"),
+		Namespace:  os.Getenv("KUBERNETES_NAMESPACE"),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		Goroutines: runtime.NumGoroutine(),
+	}
+
+	if err := response(w, http.StatusOK, data); err != nil {
+		c.Log.Error(r.Context(), "liveness", "error", err)
+	}
+}
+
+// runCheck runs a single check, giving up when the context is done so a
+// check that ignores the context can't hold up the response.
+func runCheck(ctx context.Context, check ReadinessCheck) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- check(ctx)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// response writes the data as a JSON document with the status code.
+func response(w http.ResponseWriter, statusCode int, data any) error {
+
+	// Convert the response value to JSON.
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	// Set the content type and headers once we know marshaling has succeeded.
+	w.Header().Set("Content-Type", "application/json")
+
+	// Write the status code to the response.
+	w.WriteHeader(statusCode)
+
+	// Send the result back to the client.
+	if _, err := w.Write(jsonData); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package sales_api
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/ardanlabs/service/internal/platform/logger"
+	"github.com/ardanlabs/service/internal/platform/metrics"
+)
+
+// DebugMuxConfig contains all the mandatory systems required by the debug
+// handlers.
+type DebugMuxConfig struct {
+	Build        string
+	Log          *logger.Logger
+	Checks       map[string]ReadinessCheck
+	CheckTimeout time.Duration
+}
+
+// DebugStandardLibraryMux registers all the debug routes from the standard library
+// into a new mux bypassing the use of the DefaultServerMux. Using the
+// DefaultServerMux would be a security risk since a dependency could inject a
+// handler into our service without us knowing it.
+func DebugStandardLibraryMux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// Register all the standard library debug endpoints.
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	return mux
+}
+
+// DebugMux registers all the debug standard library routes and then custom
+// debug application routes for the service. This bypasses the use of the
+// DefaultServerMux.
+func DebugMux(cfg DebugMuxConfig) http.Handler {
+	mux := DebugStandardLibraryMux()
+
+	// Register debug check endpoints.
+	cgh := Check{
+		Build:   cfg.Build,
+		Log:     cfg.Log,
+		Checks:  cfg.Checks,
+		Timeout: cfg.CheckTimeout,
+	}
+	mux.HandleFunc("/debug/readiness", cgh.Readiness)
+	mux.HandleFunc("/debug/liveness", cgh.Liveness)
+
+	// Register the Prometheus scrape endpoint.
+	mux.Handle("/metrics", metrics.Handler())
+
+	return mux
+}
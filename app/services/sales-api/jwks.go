@@ -0,0 +1,52 @@
+package sales_api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/internal/platform/web"
+)
+
+// JWKS represents the JSON Web Key Set API method handler set.
+type JWKS struct {
+	Auth   *auth.Auth
+	MaxAge time.Duration
+}
+
+// Query returns the public keys other services use to verify the tokens
+// issued by this service. Clients may cache the response for MaxAge and
+// revalidate it with the ETag.
+func (j *JWKS) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	set, err := j.Auth.JWKS()
+	if err != nil {
+		return fmt.Errorf("jwks: %w", err)
+	}
+
+	data, err := json.Marshal(set)
+	if err != nil {
+		return fmt.Errorf("marshal jwks: %w", err)
+	}
+	etag := fmt.Sprintf(`"%x"`, sha256.Sum256(data))
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(j.MaxAge.Seconds())))
+	w.Header().Set("ETag", etag)
+
+	if r.Header.Get("If-None-Match") == etag {
+		return web.Respond(ctx, w, nil, http.StatusNotModified)
+	}
+
+	// The etag is computed from the JSON document, so it's always sent as
+	// JSON whatever the client accepts, labeled as a key set when asked.
+	mediaType, ok := web.Negotiate(ctx, web.MediaTypeJSON, web.MediaTypeJWKSet)
+	if !ok {
+		mediaType = web.MediaTypeJSON
+	}
+	w.Header().Add("Vary", "Accept")
+
+	return web.RespondAs(ctx, w, set, http.StatusOK, mediaType)
+}
@@ -0,0 +1,125 @@
+package sales_api_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/app/services/sales-api"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/internal/keystore"
+	"github.com/ardanlabs/service/internal/platform/web"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_JWKS(t *testing.T) {
+	t.Log("Given the need to publish the keys that verify our tokens.")
+
+	a := newAuth(t)
+
+	app := web.New(nil, nil)
+	jwks := sales_api.JWKS{Auth: a, MaxAge: 5 * time.Minute}
+	app.Handle(http.MethodGet, "", "/.well-known/jwks.json", jwks.Query)
+
+	tt := []struct {
+		name   string
+		accept string
+		ctype  string
+	}{
+		{"no accept header", "", web.MediaTypeJSON},
+		{"a key set", "application/jwk-set+json", web.MediaTypeJWKSet},
+		{"a key set over json", "application/json;q=0.5, application/jwk-set+json", web.MediaTypeJWKSet},
+		{"json", "application/json", web.MediaTypeJSON},
+		{"an unsupported format", "text/html", web.MediaTypeJSON},
+	}
+
+	var etag string
+	for testID, tst := range tt {
+		t.Logf("\tTest %d:\tWhen asking for %s.", testID, tst.name)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+			if tst.accept != "" {
+				r.Header.Set("Accept", tst.accept)
+			}
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould respond with 200 : got %d : %s.", failed, testID, w.Code, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould respond with 200.", success, testID)
+
+			if ct := w.Header().Get("Content-Type"); ct != tst.ctype {
+				t.Fatalf("\t%s\tTest %d:\tShould respond with %s : got %s.", failed, testID, tst.ctype, ct)
+			}
+			t.Logf("\t%s\tTest %d:\tShould respond with %s.", success, testID, tst.ctype)
+
+			var set auth.JWKSet
+			if err := json.Unmarshal(w.Body.Bytes(), &set); err != nil || len(set.Keys) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould send the key set : %v : %s.", failed, testID, err, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould send the key set.", success, testID)
+
+			h := w.Header()
+			if h.Get("Cache-Control") != "public, max-age=300" || h.Get("ETag") == "" || h.Get("Vary") != "Accept" {
+				t.Fatalf("\t%s\tTest %d:\tShould send the caching headers : got %v.", failed, testID, h)
+			}
+			if etag != "" && h.Get("ETag") != etag {
+				t.Fatalf("\t%s\tTest %d:\tShould send the same etag for every format : got %s, want %s.", failed, testID, h.Get("ETag"), etag)
+			}
+			etag = h.Get("ETag")
+			t.Logf("\t%s\tTest %d:\tShould send the caching headers.", success, testID)
+		}
+	}
+
+	testID := len(tt)
+	t.Logf("\tTest %d:\tWhen revalidating the key set.", testID)
+	{
+		r := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+		r.Header.Set("Accept", web.MediaTypeJWKSet)
+		r.Header.Set("If-None-Match", etag)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Fatalf("\t%s\tTest %d:\tShould respond with 304 and no body : got %d : %s.", failed, testID, w.Code, w.Body.String())
+		}
+		t.Logf("\t%s\tTest %d:\tShould respond with 304 and no body.", success, testID)
+	}
+}
+
+// newAuth returns an authenticator signing with a new key.
+func newAuth(t *testing.T, opts ...auth.Option) *auth.Auth {
+	const kid = "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"
+
+	pk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to generate a key : %v.", failed, err)
+	}
+
+	ks, err := keystore.NewMap(map[string]crypto.Signer{kid: pk})
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to build the key store : %v.", failed, err)
+	}
+	if err := ks.Activate(kid); err != nil {
+		t.Fatalf("\t%s\tShould be able to activate the key : %v.", failed, err)
+	}
+
+	a, err := auth.New(kid, ks, opts...)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to construct auth : %v.", failed, err)
+	}
+
+	return a
+}
@@ -1,24 +1,46 @@
 package sales_api
 
 import (
-	"github.com/ardanlabs/service/business/sys/auth"
-	"github.com/ardanlabs/service/internal/mid"
+	"net/http"
 	"os"
+	"time"
 
+	"github.com/ardanlabs/service/business/core/apikey"
+	"github.com/ardanlabs/service/business/core/user"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/internal/mid"
+	"github.com/ardanlabs/service/internal/platform/logger"
 	"github.com/ardanlabs/service/internal/platform/web"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // APIMuxConfig contains all the mandatory systems required by handlers.
 type APIMuxConfig struct {
-	Shutdown chan os.Signal
-	Auth *auth.Auth
+	Shutdown      chan os.Signal
+	Log           *logger.Logger
+	Auth          *auth.Auth
+	Policy        *auth.Policy
+	UserStore     user.Storer
+	APIKeyStore   apikey.Storer
+	TokenIssuer   string
+	TokenAudience string
+	TokenExpiry   time.Duration
+	JWKSMaxAge    time.Duration
+	Tracer        trace.Tracer
 }
 
 // API returns a handler for a set of routes.
 func APIMux(cfg APIMuxConfig) *web.App {
 
 	//Construct the web.App which holds all routes as well as common Middleware
-	app := web.New(cfg.Shutdown, mid.RequestLogger, mid.Errors, mid.Panics)
+	app := web.New(cfg.Shutdown, cfg.Tracer, mid.RequestLogger(cfg.Log), mid.Metrics, mid.Compress, mid.Errors(cfg.Log), mid.Panics)
+
+	// Publish the keys that verify our tokens for other services.
+	jwks := JWKS{
+		Auth:   cfg.Auth,
+		MaxAge: cfg.JWKSMaxAge,
+	}
+	app.Handle(http.MethodGet, "", "/.well-known/jwks.json", jwks.Query)
 
 	v1(app, cfg)
 	return app
@@ -26,7 +48,42 @@ func APIMux(cfg APIMuxConfig) *web.App {
 
 func v1(app *web.App, cfg APIMuxConfig) {
 	const version = "v1"
-	var u User
-	app.Handle("GET", version, "/users", u.List)
-	app.Handle("GET", version, "/TestAuth", u.List, mid.Authenticate(cfg.Auth), mid.Authorize("ADMIN"))
+
+	// Every route in the authed group requires a token or api key.
+	v1 := app.Group(version)
+	authed := v1.Group("", mid.Authenticate(cfg.Log, cfg.Auth))
+
+	canRead := mid.RequirePermission(cfg.Auth, auth.PermUsersRead)
+	canWrite := mid.RequirePermission(cfg.Auth, auth.PermUsersWrite)
+	ownerCanRead := mid.RequireOwner(cfg.Auth, "id", auth.PermUsersRead)
+	ownerCanWrite := mid.RequireOwner(cfg.Auth, "id", auth.PermUsersWrite)
+
+	u := User{
+		Core:          user.NewCore(cfg.UserStore, cfg.Policy),
+		Auth:          cfg.Auth,
+		TokenIssuer:   cfg.TokenIssuer,
+		TokenAudience: cfg.TokenAudience,
+		TokenExpiry:   cfg.TokenExpiry,
+	}
+	v1.Handle(http.MethodGet, "/users/token", u.Token)
+	authed.Handle(http.MethodGet, "/users", u.List, canRead)
+	authed.Handle(http.MethodGet, "/users/:id", u.QueryByID, ownerCanRead)
+	authed.Handle(http.MethodPost, "/users", u.Create, canWrite)
+	authed.Handle(http.MethodPut, "/users/:id", u.Update, ownerCanWrite)
+	authed.Handle(http.MethodDelete, "/users/:id", u.Delete, canWrite)
+
+	t := Token{
+		Auth: cfg.Auth,
+	}
+	v1.Handle(http.MethodPost, "/tokens/refresh", t.Refresh)
+	authed.Handle(http.MethodPost, "/tokens/revoke", t.Revoke, mid.RequirePermission(cfg.Auth, auth.PermTokensRevoke))
+
+	k := APIKey{
+		Core: apikey.NewCore(cfg.APIKeyStore, u.Core, cfg.Policy),
+		Auth: cfg.Auth,
+	}
+	apikeys := authed.Group("/apikeys")
+	apikeys.Handle(http.MethodGet, "", k.List, mid.RequirePermission(cfg.Auth, auth.PermAPIKeysRead))
+	apikeys.Handle(http.MethodPost, "", k.Create, mid.RequirePermission(cfg.Auth, auth.PermAPIKeysWrite))
+	apikeys.Handle(http.MethodDelete, "/:id", k.Revoke, mid.RequirePermission(cfg.Auth, auth.PermAPIKeysWrite))
 }
@@ -0,0 +1,63 @@
+package sales_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/internal/platform/web"
+)
+
+// Token represents the token API method handler set.
+type Token struct {
+	Auth *auth.Auth
+}
+
+// revokeRequest is the document the Revoke endpoint accepts.
+type revokeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// Revoke rejects the token from now on, even though it hasn't expired.
+func (t *Token) Revoke(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var rr revokeRequest
+	if err := web.Decode(r, &rr); err != nil {
+		return err
+	}
+
+	if err := t.Auth.RevokeToken(ctx, rr.Token); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, auth.ErrMissingClaim) {
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		}
+		return fmt.Errorf("revoking token: %w", err)
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// refreshRequest is the document the Refresh endpoint accepts.
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token. Each
+// refresh token can only be used once.
+func (t *Token) Refresh(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var rr refreshRequest
+	if err := web.Decode(r, &rr); err != nil {
+		return err
+	}
+
+	tp, err := t.Auth.RefreshTokenPair(ctx, rr.RefreshToken)
+	if err != nil {
+		if errors.Is(err, auth.ErrRefreshTokenInvalid) || errors.Is(err, auth.ErrRefreshTokenReused) {
+			return validate.NewRequestError(err, http.StatusUnauthorized)
+		}
+		return fmt.Errorf("refreshing token: %w", err)
+	}
+
+	return web.Respond(ctx, w, tp, http.StatusOK)
+}
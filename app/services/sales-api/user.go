@@ -2,24 +2,206 @@ package sales_api
 
 import (
 	"context"
-	"github.com/ardanlabs/service/internal/platform/web"
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/user"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/internal/platform/web"
+	"github.com/golang-jwt/jwt/v4"
 )
 
 // User represents the User API method handler set.
 type User struct {
-	// ADD OTHER STATE LIKE THE LOGGER AND CONFIG HERE.
+	Core          *user.Core
+	Auth          *auth.Auth
+	TokenIssuer   string
+	TokenAudience string
+	TokenExpiry   time.Duration
 }
 
-// List returns all the existing users in the system.
+// userQuery declares the paging, ordering and filtering the List endpoint
+// supports.
+var userQuery = web.QueryConfig{
+	OrderFields: map[string]string{
+		"id":          user.OrderByID,
+		"name":        user.OrderByName,
+		"email":       user.OrderByEmail,
+		"dateCreated": user.OrderByDateCreated,
+	},
+	DefaultOrder: web.OrderBy{Field: user.OrderByID, Direction: web.ASC},
+	Filters:      []string{"id", "name", "email", "role"},
+}
+
+// List returns a page of the existing users in the system.
 func (u *User) List(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	data := struct {
-		Name  string
-		Email string
-	}{
-		Name:  "Bill",
-		Email: "bill@ardanlabs.com",
-	}
-	web.Respond(ctx, w, data, http.StatusOK)
-	return nil
+	q, err := web.ParseQuery(r, userQuery)
+	if err != nil {
+		return err
+	}
+
+	filter := userFilter(q)
+	orderBy := user.OrderBy{
+		Field:      q.OrderBy.Field,
+		Descending: q.OrderBy.Direction == web.DESC,
+	}
+
+	users, err := u.Core.Query(ctx, filter, orderBy, q.Page, q.RowsPerPage)
+	if err != nil {
+		return fmt.Errorf("unable to query for users: %w", err)
+	}
+
+	total, err := u.Core.Count(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("unable to count users: %w", err)
+	}
+
+	return web.Respond(ctx, w, web.NewPageDocument(users, total, q), http.StatusOK)
+}
+
+// QueryByID returns a user by its ID.
+func (u *User) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id := web.Param(r, "id")
+
+	usr, err := u.Core.QueryByID(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrInvalidID):
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, user.ErrNotFound):
+			return validate.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, usr, http.StatusOK)
+}
+
+// Create adds a new user to the system.
+func (u *User) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var nu user.NewUser
+	if err := web.Decode(r, &nu); err != nil {
+		return err
+	}
+
+	usr, err := u.Core.Create(ctx, nu, web.GetTime(ctx))
+	if err != nil {
+		if errors.Is(err, user.ErrUniqueEmail) {
+			return validate.NewRequestError(err, http.StatusConflict)
+		}
+		return fmt.Errorf("create: %w", err)
+	}
+
+	return web.Respond(ctx, w, usr, http.StatusCreated)
+}
+
+// Update updates a user in the system. Only users allowed to write any user
+// may change roles.
+func (u *User) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var uu user.UpdateUser
+	if err := web.Decode(r, &uu); err != nil {
+		return err
+	}
+
+	// Owners may update their own record, but not grant themselves roles.
+	if uu.Roles != nil && !u.Auth.HasPermissions(auth.GetClaims(ctx), auth.PermUsersWrite) {
+		err := fmt.Errorf("changing roles: %w", auth.ErrForbidden)
+		return validate.NewRequestError(err, http.StatusForbidden)
+	}
+
+	id := web.Param(r, "id")
+
+	usr, err := u.Core.Update(ctx, id, uu, web.GetTime(ctx))
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrInvalidID):
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, user.ErrNotFound):
+			return validate.NewRequestError(err, http.StatusNotFound)
+		case errors.Is(err, user.ErrUniqueEmail):
+			return validate.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, usr, http.StatusOK)
+}
+
+// Delete removes a user from the system.
+func (u *User) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id := web.Param(r, "id")
+
+	if err := u.Core.Delete(ctx, id); err != nil {
+		switch {
+		case errors.Is(err, user.ErrInvalidID):
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, user.ErrNotFound):
+			return validate.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Token provides an API token for the authenticated user.
+func (u *User) Token(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	email, pass, ok := r.BasicAuth()
+	if !ok {
+		err := errors.New("must provide email and password in Basic auth")
+		return validate.NewRequestError(err, http.StatusUnauthorized)
+	}
+
+	usr, err := u.Core.Authenticate(ctx, email, pass)
+	if err != nil {
+		if errors.Is(err, user.ErrAuthenticationFailure) {
+			return validate.NewRequestError(err, http.StatusUnauthorized)
+		}
+		return fmt.Errorf("authenticating: %w", err)
+	}
+
+	now := web.GetTime(ctx).UTC()
+	claims := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   usr.ID,
+			Issuer:    u.TokenIssuer,
+			Audience:  jwt.ClaimStrings{u.TokenAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(u.TokenExpiry)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		Roles: usr.Roles,
+	}
+
+	tp, err := u.Auth.GenerateTokenPair(ctx, claims)
+	if err != nil {
+		return fmt.Errorf("generating token: %w", err)
+	}
+
+	return web.Respond(ctx, w, tp, http.StatusOK)
+}
+
+// userFilter builds the user filter from the parsed query string.
+func userFilter(q web.Query) user.QueryFilter {
+	var filter user.QueryFilter
+
+	if v, exists := q.Filters["id"]; exists {
+		filter.ID = &v
+	}
+	if v, exists := q.Filters["name"]; exists {
+		filter.Name = &v
+	}
+	if v, exists := q.Filters["email"]; exists {
+		filter.Email = &v
+	}
+	if v, exists := q.Filters["role"]; exists {
+		filter.Role = &v
+	}
+
+	return filter
 }
@@ -0,0 +1,61 @@
+// Package commands contains the functionality for the set of commands
+// currently supported by the admin tool.
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/ardanlabs/service/internal/keystore"
+)
+
+// ErrHelp provides context that help was given.
+var ErrHelp = errors.New("provided help")
+
+// KeyConfig declares where the keys are found and which one is used.
+type KeyConfig struct {
+	Folder        string
+	ActiveKIDFile string
+	KID           string
+}
+
+// loadKeys constructs a key store from the keys folder and activates the
+// kid. An empty kid selects the one in the active kid file, the same as the
+// service does. Without the file the only key in the folder is used.
+func loadKeys(cfg KeyConfig) (*keystore.KeyStore, string, error) {
+	ks, err := keystore.NewFS(os.DirFS(cfg.Folder))
+	if err != nil {
+		return nil, "", fmt.Errorf("reading keys: %w", err)
+	}
+
+	kid := cfg.KID
+	if kid == "" {
+		data, err := os.ReadFile(cfg.ActiveKIDFile)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			kids := ks.KIDs()
+			if len(kids) != 1 {
+				return nil, "", fmt.Errorf("no kid provided, no active kid file found and %d keys to choose from", len(kids))
+			}
+			kid = kids[0]
+
+		case err != nil:
+			return nil, "", fmt.Errorf("reading active kid file: %w", err)
+
+		default:
+			kid = strings.TrimSpace(string(data))
+			if kid == "" {
+				return nil, "", fmt.Errorf("active kid file %q is empty", cfg.ActiveKIDFile)
+			}
+		}
+	}
+
+	if err := ks.Activate(kid); err != nil {
+		return nil, "", fmt.Errorf("activating key %q: %w", kid, err)
+	}
+
+	return ks, kid, nil
+}
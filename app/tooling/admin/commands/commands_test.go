@@ -0,0 +1,201 @@
+package commands_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/app/tooling/admin/commands"
+	"github.com/ardanlabs/service/business/core/user/stores/userdb"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/internal/keystore"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_Keys(t *testing.T) {
+	t.Log("Given the need to manage keys and tokens from the command line.")
+	{
+		folder := t.TempDir()
+		keys := commands.KeyConfig{
+			Folder:        folder,
+			ActiveKIDFile: filepath.Join(folder, "active.kid"),
+		}
+
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the keys folder holds a single key.", testID)
+		{
+			first, err := generate(folder, auth.AlgRS256)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a key : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to generate a key.", success, testID)
+
+			out, err := capture(func() error { return commands.PublicKey(keys) })
+			if err != nil || !samePublicKey(t, folder, first, out) {
+				t.Fatalf("\t%s\tTest %d:\tShould use the only key without a kid : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould use the only key without a kid.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen the keys folder holds several keys.", testID)
+		{
+			second, err := generate(folder, auth.AlgES256)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a key : %v.", failed, testID, err)
+			}
+
+			if _, err := capture(func() error { return commands.PublicKey(keys) }); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould require a kid without an active kid file.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould require a kid without an active kid file.", success, testID)
+
+			if err := os.WriteFile(keys.ActiveKIDFile, []byte(second+"\n"), 0600); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to write the active kid file : %v.", failed, testID, err)
+			}
+
+			out, err := capture(func() error { return commands.PublicKey(keys) })
+			if err != nil || !samePublicKey(t, folder, second, out) {
+				t.Fatalf("\t%s\tTest %d:\tShould use the kid of the active kid file : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould use the kid of the active kid file.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen generating and verifying a token.", testID)
+		{
+			cfg := commands.TokenConfig{
+				Subject:  "45b5fbd3-755f-4379-8f07-a58d4a30fa2f",
+				Roles:    []string{auth.RoleAdmin},
+				TTL:      time.Hour,
+				Issuer:   "service project",
+				Audience: "sales-api",
+			}
+
+			out, err := capture(func() error { return commands.GenToken(keys, cfg) })
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a token : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to generate a token.", success, testID)
+
+			token := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(out), "-----BEGIN TOKEN-----"), "-----END TOKEN-----"))
+
+			out, err = capture(func() error { return commands.VerifyToken(keys, token, cfg.Issuer, cfg.Audience) })
+			if err != nil || !strings.Contains(out, cfg.Subject) {
+				t.Fatalf("\t%s\tTest %d:\tShould verify the token and print its claims : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould verify the token and print its claims.", success, testID)
+
+			if _, err := capture(func() error { return commands.VerifyToken(keys, token, cfg.Issuer, "other") }); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a token for another audience.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject a token for another audience.", success, testID)
+		}
+	}
+}
+
+func Test_Seed(t *testing.T) {
+	t.Log("Given the need to prepare the user database.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen migrating and seeding the database twice.", testID)
+		{
+			path := filepath.Join(t.TempDir(), "users.json")
+
+			for i := 0; i < 2; i++ {
+				if _, err := capture(func() error { return commands.Migrate(path) }); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to migrate the database : %v.", failed, testID, err)
+				}
+				if _, err := capture(func() error { return commands.Seed(path) }); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to seed the database : %v.", failed, testID, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to migrate and seed the database.", success, testID)
+
+			store, err := userdb.NewStore(path)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to open the database : %v.", failed, testID, err)
+			}
+
+			for _, email := range []string{"admin@example.com", "user@example.com"} {
+				if _, err := store.QueryByEmail(context.Background(), email); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould create the account %s : %v.", failed, testID, email, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould create the development accounts.", success, testID)
+		}
+	}
+}
+
+// generate creates a key in the folder and returns its kid.
+func generate(folder string, algorithm string) (string, error) {
+	var kid string
+	_, err := capture(func() error {
+		var err error
+		kid, err = commands.GenKey(folder, algorithm)
+		return err
+	})
+	return kid, err
+}
+
+// samePublicKey reports if the PEM output is the public key of the kid.
+func samePublicKey(t *testing.T, folder string, kid string, out string) bool {
+	ks, err := keystore.NewFS(os.DirFS(folder))
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to read the keys : %v.", failed, err)
+	}
+
+	want, err := ks.PublicKeyPEM(kid)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to look up the key : %v.", failed, err)
+	}
+
+	block, _ := pem.Decode([]byte(out))
+	if block == nil {
+		return false
+	}
+
+	got, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return false
+	}
+
+	type equaler interface {
+		Equal(x crypto.PublicKey) bool
+	}
+	return got.(equaler).Equal(want)
+}
+
+// capture runs the command and returns what it printed.
+func capture(fn func() error) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+
+	err = fn()
+	w.Close()
+
+	return <-out, err
+}
@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/internal/keystore"
+	"github.com/google/uuid"
+)
+
+// GenKey creates a private key for the algorithm and writes it to the keys
+// folder named after a new kid. The algorithm is recorded in the PEM file
+// when it isn't the default for the key type.
+func GenKey(folder string, algorithm string) (string, error) {
+	var signer crypto.Signer
+	var err error
+
+	switch algorithm {
+	case auth.AlgRS256, auth.AlgPS256:
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	case auth.AlgES256:
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case auth.AlgES384:
+		signer, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case auth.AlgEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return "", fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+	if err != nil {
+		return "", fmt.Errorf("generating key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		return "", fmt.Errorf("marshaling private key: %w", err)
+	}
+
+	block := pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: der,
+	}
+
+	if def, err := auth.DefaultAlgorithm(signer.Public()); err == nil && def != algorithm {
+		block.Headers = map[string]string{keystore.AlgorithmHeader: algorithm}
+	}
+
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return "", fmt.Errorf("creating keys folder: %w", err)
+	}
+
+	kid := uuid.New().String()
+	name := filepath.Join(folder, kid+".pem")
+
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", fmt.Errorf("creating private file: %w", err)
+	}
+	defer file.Close()
+
+	if err := pem.Encode(file, &block); err != nil {
+		return "", fmt.Errorf("encoding to private file: %w", err)
+	}
+
+	fmt.Printf("private key file %s created for kid %s\n", name, kid)
+	fmt.Println("write the kid to the active kid file and send SIGHUP to the service to start signing with it")
+
+	return kid, nil
+}
@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// TokenConfig declares the claims of a generated token.
+type TokenConfig struct {
+	Subject  string
+	Roles    []string
+	TTL      time.Duration
+	Issuer   string
+	Audience string
+}
+
+// GenToken generates a token signed with the key and prints it.
+func GenToken(keys KeyConfig, cfg TokenConfig) error {
+	if cfg.Subject == "" {
+		return fmt.Errorf("a subject is required")
+	}
+
+	ks, kid, err := loadKeys(keys)
+	if err != nil {
+		return err
+	}
+
+	a, err := auth.New(kid, ks)
+	if err != nil {
+		return fmt.Errorf("constructing auth: %w", err)
+	}
+
+	// Generating a token requires defining a set of claims. In this
+	// applications case, we only care about defining the subject and the user
+	// in question and the roles they have on the database. A unique token id
+	// is set so the token can be revoked.
+	//
+	// iss (issuer): Issuer of the JWT
+	// sub (subject): Subject of the JWT (the user)
+	// aud (audience): Recipient for which the JWT is intended
+	// exp (expiration time): Time after which the JWT expires
+	// iat (issued at time): Time at which the JWT was issued
+	// jti (JWT ID): Unique identifier of the JWT
+	now := time.Now().UTC()
+	claims := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   cfg.Subject,
+			Issuer:    cfg.Issuer,
+			ExpiresAt: jwt.NewNumericDate(now.Add(cfg.TTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		Roles: cfg.Roles,
+	}
+	if cfg.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{cfg.Audience}
+	}
+
+	token, err := a.GenerateToken(claims)
+	if err != nil {
+		return fmt.Errorf("generating token: %w", err)
+	}
+
+	fmt.Printf("-----BEGIN TOKEN-----\n%s\n-----END TOKEN-----\n", token)
+	return nil
+}
@@ -0,0 +1,24 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ardanlabs/service/business/core/user/stores/userdb"
+)
+
+// Migrate writes the user database file in the current format, creating it
+// when it doesn't exist.
+func Migrate(path string) error {
+	store, err := userdb.NewStore(path)
+	if err != nil {
+		return fmt.Errorf("opening user store: %w", err)
+	}
+
+	if err := store.Migrate(context.Background()); err != nil {
+		return fmt.Errorf("migrating user store: %w", err)
+	}
+
+	fmt.Println("migrations complete")
+	return nil
+}
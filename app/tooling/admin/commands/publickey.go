@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+)
+
+// PublicKey prints the public key of the kid in PEM form.
+func PublicKey(keys KeyConfig) error {
+	ks, kid, err := loadKeys(keys)
+	if err != nil {
+		return err
+	}
+
+	publicKey, err := ks.PublicKeyPEM(kid)
+	if err != nil {
+		return fmt.Errorf("looking up public key: %w", err)
+	}
+
+	// Marshal the public key from the private key to PKIX.
+	asn1Bytes, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("marshaling public key: %w", err)
+	}
+
+	publicBlock := pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: asn1Bytes,
+	}
+
+	if err := pem.Encode(os.Stdout, &publicBlock); err != nil {
+		return fmt.Errorf("encoding public key: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/user"
+	"github.com/ardanlabs/service/business/core/user/stores/userdb"
+	"github.com/ardanlabs/service/business/sys/auth"
+)
+
+// seedUsers are the accounts created for development.
+var seedUsers = []user.NewUser{
+	{
+		Name:            "Admin Gopher",
+		Email:           "admin@example.com",
+		Roles:           []string{auth.RoleAdmin},
+		Password:        "gophers",
+		PasswordConfirm: "gophers",
+	},
+	{
+		Name:            "User Gopher",
+		Email:           "user@example.com",
+		Roles:           []string{auth.RoleUser},
+		Password:        "gophers",
+		PasswordConfirm: "gophers",
+	},
+}
+
+// Seed creates the development accounts in the user database. Accounts that
+// already exist are left untouched.
+func Seed(path string) error {
+	store, err := userdb.NewStore(path)
+	if err != nil {
+		return fmt.Errorf("opening user store: %w", err)
+	}
+
+	ctx := context.Background()
+	// The development accounts only hold the built in roles.
+	core := user.NewCore(store, auth.DefaultPolicy())
+
+	for _, nu := range seedUsers {
+		usr, err := core.Create(ctx, nu, time.Now())
+		if err != nil {
+			if errors.Is(err, user.ErrUniqueEmail) {
+				fmt.Printf("user %s already exists\n", nu.Email)
+				continue
+			}
+			return fmt.Errorf("creating user %s: %w", nu.Email, err)
+		}
+		fmt.Printf("user %s created with id %s\n", usr.Email, usr.ID)
+	}
+
+	fmt.Println("seed data complete")
+	return nil
+}
@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ardanlabs/service/business/sys/auth"
+)
+
+// VerifyToken validates the token against the keys and prints its claims.
+// The issuer and audience are only checked when provided.
+func VerifyToken(keys KeyConfig, token string, issuer string, audience string) error {
+	ks, kid, err := loadKeys(keys)
+	if err != nil {
+		return err
+	}
+
+	var opts []auth.Option
+	if issuer != "" {
+		opts = append(opts, auth.WithIssuer(issuer))
+	}
+	if audience != "" {
+		opts = append(opts, auth.WithAudiences(audience))
+	}
+
+	a, err := auth.New(kid, ks, opts...)
+	if err != nil {
+		return fmt.Errorf("constructing auth: %w", err)
+	}
+
+	claims, err := a.ValidateToken(context.Background(), token)
+	if err != nil {
+		return fmt.Errorf("validating token: %w", err)
+	}
+
+	data, err := json.MarshalIndent(claims, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding claims: %w", err)
+	}
+
+	fmt.Println("token is valid")
+	fmt.Println(string(data))
+	return nil
+}
@@ -1,134 +1,130 @@
+// This program performs administrative tasks for the sales service.
 package main
 
 import (
-	"crypto/x509"
-	"encoding/pem"
 	"errors"
+	"flag"
 	"fmt"
-	//"github.com/google/uuid"
-	"io"
-	"log"
 	"os"
-	"github.com/golang-jwt/jwt/v4"
+	"strings"
 	"time"
-)
-type Claims struct {
-	jwt.RegisteredClaims
-	Roles []string `json:"roles"`
-}
-
-func main() {
-	err := genToken()
-
-	if err != nil {
-		log.Println(err)
-		os.Exit(1)
-	}
-}
 
-func genToken() error {
+	"github.com/ardanlabs/service/app/tooling/admin/commands"
+	"github.com/ardanlabs/service/business/sys/auth"
+)
 
-	name:= "zarf\\keys\\54bb2165-71e1-41a6-af3e-7da4a0e1e2c1.pem"
-	file, err := os.Open(name)
-	if err != nil {
-		return err
-	}
+const usage = `Usage: admin <command> [options]
 
-	privatePEM, err := io.ReadAll(io.LimitReader(file, 1024*1024))
-	if err != nil {
-		return fmt.Errorf("reading auth private key: %w", err)
-	}
+COMMANDS
+  genkey       create a new private key in the keys folder
+  gentoken     generate a token signed with a key
+  verifytoken  validate a token and print its claims: verifytoken [options] <jwt>
+  publickey    print the public key of a key
+  migrate      create or update the user database file
+  seed         create the development accounts in the user database
 
-	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(privatePEM))
-	if err != nil {
-		return fmt.Errorf("parsing private pem: %w", err)
-	}
+Run admin <command> --help for the options of a command.`
 
-	// Generating a token requires defining a set of claims. In this applications
-	// case, we only care about defining the subject and the user in question and
-	// the roles they have on the database. This token will expire in a year.
-	//
-	// iss (issuer): Issuer of the JWT
-	// sub (subject): Subject of the JWT (the user)
-	// aud (audience): Recipient for which the JWT is intended
-	// exp (expiration time): Time after which the JWT expires
-	// nbf (not before time): Time before which the JWT must not be accepted for processing
-	// iat (issued at time): Time at which the JWT was issued; can be used to determine age of the JWT
-	// jti (JWT ID): Unique identifier; can be used to prevent the JWT from being replayed (allows a token to be used only once)
-	claims := Claims{
-		RegisteredClaims: jwt.RegisteredClaims{
-			Subject:   "1234",
-			Issuer:    "service project",
-			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(8760 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		},
-		Roles: []string{"ADMIN"},
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		if !errors.Is(err, commands.ErrHelp) {
+			fmt.Println("ERROR", err)
+		}
+		os.Exit(1)
 	}
-	method := jwt.GetSigningMethod("RS256")
-	token := jwt.NewWithClaims(method, claims)
-	token.Header["kid"] = "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"
-
+}
 
-	tokenStr, err := token.SignedString(privateKey)
-	if err != nil {
-		return fmt.Errorf("signing token: %w", err)
+func run(args []string) error {
+	if len(args) == 0 {
+		fmt.Println(usage)
+		return commands.ErrHelp
 	}
 
-	fmt.Println("===== TOKEN BEGIN =====")
-	fmt.Println(tokenStr)
-	fmt.Println("===== TOKEN END =====")
-	fmt.Println("\n")
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
 
-	// Marshal the public key from the private key to PKIX.
-	asn1Bytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
-	if err != nil {
-		return fmt.Errorf("marshaling public key: %w", err)
+	var keys commands.KeyConfig
+	keyFlags := func() {
+		fs.StringVar(&keys.Folder, "folder", "zarf/keys/", "folder holding the private key files")
+		fs.StringVar(&keys.ActiveKIDFile, "active-kid-file", "zarf/keys/active.kid", "file holding the active kid")
+		fs.StringVar(&keys.KID, "kid", "", "key id, empty uses the active kid file")
 	}
 
-	// Create a file for the public key information in PEM form.
-	publicFile, err := os.Create("public.pem")
-	if err != nil {
-		return fmt.Errorf("creating public file: %w", err)
+	dbPath := func() *string {
+		return fs.String("db", "zarf/db/users.json", "path of the user database file")
 	}
-	defer publicFile.Close()
 
-	// Construct a PEM block for the public key.
-	publicBlock := pem.Block{
-		Type:  "PUBLIC KEY",
-		Bytes: asn1Bytes,
+	parse := func() error {
+		if err := fs.Parse(args[1:]); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return commands.ErrHelp
+			}
+			return err
+		}
+		return nil
 	}
 
-	// Write the public key to the public key file.
-	if err := pem.Encode(os.Stdout, &publicBlock); err != nil {
-		return fmt.Errorf("encoding to public file: %w", err)
-	}
+	switch args[0] {
+	case "genkey":
+		folder := fs.String("folder", "zarf/keys/", "folder the private key file is written to")
+		alg := fs.String("alg", auth.AlgRS256, "signing algorithm: RS256, PS256, ES256, ES384 or EdDSA")
+		if err := parse(); err != nil {
+			return err
+		}
+		_, err := commands.GenKey(*folder, *alg)
+		return err
 
-	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256"}))
+	case "gentoken":
+		keyFlags()
+		var cfg commands.TokenConfig
+		fs.StringVar(&cfg.Subject, "sub", "", "subject of the token, usually a user id")
+		roles := fs.String("roles", auth.RoleUser, "comma separated list of roles")
+		fs.DurationVar(&cfg.TTL, "ttl", time.Hour, "how long the token is valid")
+		fs.StringVar(&cfg.Issuer, "iss", "service project", "issuer of the token")
+		fs.StringVar(&cfg.Audience, "aud", "sales-api", "audience of the token")
+		if err := parse(); err != nil {
+			return err
+		}
+		cfg.Roles = strings.Split(*roles, ",")
+		return commands.GenToken(keys, cfg)
+
+	case "verifytoken":
+		keyFlags()
+		iss := fs.String("iss", "", "issuer the token must have")
+		aud := fs.String("aud", "", "audience the token must have")
+		if err := parse(); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return errors.New("verifytoken requires the token as its only argument")
+		}
+		return commands.VerifyToken(keys, fs.Arg(0), *iss, *aud)
 
-	keyFunc := func(t *jwt.Token) (interface{}, error) {
-		kid, ok := t.Header["kid"]
-		if !ok {
-			return nil, fmt.Errorf("missing key id (kid) in token header")
+	case "publickey":
+		keyFlags()
+		if err := parse(); err != nil {
+			return err
 		}
-		kidID, ok := kid.(string)
-		if !ok {
-			return nil, errors.New("user token key id (kid) must be string")
+		return commands.PublicKey(keys)
+
+	case "migrate":
+		path := dbPath()
+		if err := parse(); err != nil {
+			return err
 		}
-		fmt.Println("KID",kidID)
-		return &privateKey.PublicKey, nil
-	}
+		return commands.Migrate(*path)
 
-	var parsedclaims Claims
-	parsedToken, err := parser.ParseWithClaims(tokenStr, &parsedclaims, keyFunc)
-	if err != nil {
-		return fmt.Errorf("parssing token: %w", err)
-	}
+	case "seed":
+		path := dbPath()
+		if err := parse(); err != nil {
+			return err
+		}
+		return commands.Seed(*path)
 
-	if !parsedToken.Valid {
-		return errors.New("invalid token")
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+		return commands.ErrHelp
 	}
-	fmt.Println("===============")
-	fmt.Println("Token Validated")
 
-	return nil
+	fmt.Println(usage)
+	return fmt.Errorf("unknown command %q", args[0])
 }
@@ -0,0 +1,246 @@
+// Package apikey provides a core business API for managing the API keys
+// batch jobs and partner integrations authenticate with.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+// Set of error variables for CRUD operations.
+var (
+	ErrNotFound              = errors.New("api key not found")
+	ErrInvalidID             = errors.New("ID is not in its proper form")
+	ErrAuthenticationFailure = errors.New("authentication failed")
+)
+
+// keyPrefix marks the keys issued by this service so they are easy to
+// recognize, for example by secret scanners.
+const keyPrefix = "sak_"
+
+// Storer interface declares the behavior this package needs to persist and
+// retrieve data.
+type Storer interface {
+	Create(ctx context.Context, key APIKey) error
+	Update(ctx context.Context, key APIKey) error
+	Query(ctx context.Context, filter QueryFilter, orderBy OrderBy, pageNumber int, rowsPerPage int) ([]APIKey, error)
+	Count(ctx context.Context, filter QueryFilter) (int, error)
+	QueryByID(ctx context.Context, keyID string) (APIKey, error)
+	QueryByHash(ctx context.Context, hash string) (APIKey, error)
+}
+
+// Core manages the set of APIs for api key access.
+type Core struct {
+	storer Storer
+	users  auth.UserLookup
+	policy *auth.Policy
+}
+
+// NewCore constructs a core for api key access. The users are looked up to
+// check the owner of a key still exists with the roles of the key, and the
+// policy defines the roles a key can be scoped to.
+func NewCore(storer Storer, users auth.UserLookup, policy *auth.Policy) *Core {
+	return &Core{
+		storer: storer,
+		users:  users,
+		policy: policy,
+	}
+}
+
+// Create generates a new key for the user of the claims. The key can only be
+// scoped to roles the claims hold, so a key never grants more than its
+// owner has. The key is returned along with its record and can't be
+// retrieved again.
+func (c *Core) Create(ctx context.Context, claims auth.Claims, nk NewAPIKey, now time.Time) (APIKey, string, error) {
+	if err := nk.Validate(); err != nil {
+		return APIKey{}, "", err
+	}
+
+	for _, role := range nk.Roles {
+		if !c.policy.HasRole(role) {
+			return APIKey{}, "", validate.FieldErrors{{
+				Field: "roles",
+				Error: fmt.Sprintf("roles has %q which is not a defined role", role),
+			}}
+		}
+		if !claims.Authorized(role) {
+			return APIKey{}, "", fmt.Errorf("%w: role %q is not held by the user", auth.ErrForbidden, role)
+		}
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return APIKey{}, "", fmt.Errorf("generating key: %w", err)
+	}
+	secret := keyPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	key := APIKey{
+		ID:          uuid.New().String(),
+		Name:        nk.Name,
+		Prefix:      secret[:len(keyPrefix)+6],
+		Hash:        hashKey(secret),
+		UserID:      claims.Subject,
+		Roles:       nk.Roles,
+		DateCreated: now,
+		DateExpires: nk.DateExpires,
+	}
+
+	if err := c.storer.Create(ctx, key); err != nil {
+		return APIKey{}, "", fmt.Errorf("create: %w", err)
+	}
+
+	return key, secret, nil
+}
+
+// Revoke stops the key from being used. The record is kept so it still
+// shows up when listing keys.
+func (c *Core) Revoke(ctx context.Context, keyID string, now time.Time) (APIKey, error) {
+	key, err := c.QueryByID(ctx, keyID)
+	if err != nil {
+		return APIKey{}, fmt.Errorf("query: %w", err)
+	}
+
+	if key.DateRevoked != nil {
+		return key, nil
+	}
+	key.DateRevoked = &now
+
+	if err := c.storer.Update(ctx, key); err != nil {
+		return APIKey{}, fmt.Errorf("update: %w", err)
+	}
+
+	return key, nil
+}
+
+// Query retrieves a list of existing api keys from the database. Revoked
+// keys are included.
+func (c *Core) Query(ctx context.Context, filter QueryFilter, orderBy OrderBy, pageNumber int, rowsPerPage int) ([]APIKey, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	keys, err := c.storer.Query(ctx, filter, orderBy, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return keys, nil
+}
+
+// Count returns the total number of api keys matching the filter.
+func (c *Core) Count(ctx context.Context, filter QueryFilter) (int, error) {
+	if err := filter.Validate(); err != nil {
+		return 0, err
+	}
+
+	total, err := c.storer.Count(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("count: %w", err)
+	}
+
+	return total, nil
+}
+
+// QueryByID gets the specified key from the database.
+func (c *Core) QueryByID(ctx context.Context, keyID string) (APIKey, error) {
+	if _, err := uuid.Parse(keyID); err != nil {
+		return APIKey{}, ErrInvalidID
+	}
+
+	key, err := c.storer.QueryByID(ctx, keyID)
+	if err != nil {
+		return APIKey{}, fmt.Errorf("query: %w", err)
+	}
+
+	return key, nil
+}
+
+// Authenticate finds the key and checks it can still be used. An unknown,
+// revoked or expired key reports ErrAuthenticationFailure.
+func (c *Core) Authenticate(ctx context.Context, secret string, now time.Time) (APIKey, error) {
+	if !strings.HasPrefix(secret, keyPrefix) {
+		return APIKey{}, ErrAuthenticationFailure
+	}
+
+	key, err := c.storer.QueryByHash(ctx, hashKey(secret))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return APIKey{}, ErrAuthenticationFailure
+		}
+		return APIKey{}, fmt.Errorf("query: %w", err)
+	}
+
+	if !key.Active(now) {
+		return APIKey{}, ErrAuthenticationFailure
+	}
+
+	return key, nil
+}
+
+// AuthenticateAPIKey implements the auth.APIKeyAuthenticator interface. The
+// claims carry the user the key belongs to and the roles the key is scoped
+// to, the same as the claims of a token. The key stops working once its
+// owner is deleted or no longer holds one of its roles.
+func (c *Core) AuthenticateAPIKey(ctx context.Context, secret string) (auth.Claims, error) {
+	key, err := c.Authenticate(ctx, secret, time.Now())
+	if err != nil {
+		return auth.Claims{}, err
+	}
+
+	roles, err := c.users.UserRoles(ctx, key.UserID)
+	if err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			return auth.Claims{}, ErrAuthenticationFailure
+		}
+		return auth.Claims{}, fmt.Errorf("query user: %w", err)
+	}
+
+	for _, role := range key.Roles {
+		if !contains(roles, role) {
+			return auth.Claims{}, ErrAuthenticationFailure
+		}
+	}
+
+	claims := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       key.ID,
+			Subject:  key.UserID,
+			IssuedAt: jwt.NewNumericDate(key.DateCreated),
+		},
+		Roles: key.Roles,
+	}
+	if key.DateExpires != nil {
+		claims.ExpiresAt = jwt.NewNumericDate(*key.DateExpires)
+	}
+
+	return claims, nil
+}
+
+// contains reports if the value is in the list.
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// hashKey returns the hash a key is stored under. The keys are random so a
+// fast hash is enough to keep them safe at rest.
+func hashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,192 @@
+package apikey_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/apikey"
+	"github.com/ardanlabs/service/business/core/apikey/stores/apikeydb"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/sys/validate"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_APIKey(t *testing.T) {
+	t.Log("Given the need to work with APIKey records.")
+
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a single APIKey.", testID)
+		{
+			path := filepath.Join(t.TempDir(), "apikeys.json")
+			store, err := apikeydb.NewStore(path)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to open the store: %v.", failed, testID, err)
+			}
+
+			const userID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+			users := userLookup{userID: {auth.RoleUser}}
+
+			core := apikey.NewCore(store, users, auth.DefaultPolicy())
+			ctx := context.Background()
+			now := time.Now()
+
+			nk := apikey.NewAPIKey{
+				Name:  "nightly batch",
+				Roles: []string{auth.RoleUser},
+			}
+
+			key, secret, err := core.Create(ctx, claimsOf(userID, auth.RoleUser), nk, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create the key : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create the key.", success, testID)
+
+			reopened, err := apikeydb.NewStore(path)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to reopen the store: %v.", failed, testID, err)
+			}
+			core = apikey.NewCore(reopened, users, auth.DefaultPolicy())
+
+			claims, err := core.AuthenticateAPIKey(ctx, secret)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to authenticate with the key : %s.", failed, testID, err)
+			}
+			if claims.Subject != userID || !claims.Authorized(auth.RoleUser) || claims.Authorized(auth.RoleAdmin) {
+				t.Fatalf("\t%s\tTest %d:\tShould produce claims scoped to the key : got %+v", failed, testID, claims)
+			}
+			t.Logf("\t%s\tTest %d:\tShould produce claims scoped to the key.", success, testID)
+
+			if _, err := core.Authenticate(ctx, secret+"x", now); !errors.Is(err, apikey.ErrAuthenticationFailure) {
+				t.Fatalf("\t%s\tTest %d:\tShould reject an unknown key : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject an unknown key.", success, testID)
+
+			if _, err := core.Revoke(ctx, key.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke the key : %s.", failed, testID, err)
+			}
+
+			if _, err := core.Authenticate(ctx, secret, now); !errors.Is(err, apikey.ErrAuthenticationFailure) {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a revoked key : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject a revoked key.", success, testID)
+
+			id := userID
+			filter := apikey.QueryFilter{UserID: &id}
+
+			keys, err := core.Query(ctx, filter, apikey.DefaultOrderBy, 1, 10)
+			if err != nil || len(keys) != 1 || keys[0].DateRevoked == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould list the revoked key : got %+v, %v.", failed, testID, keys, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould list the revoked key.", success, testID)
+
+			other := "5cf37266-3473-4006-984f-9325122678b7"
+			if total, err := core.Count(ctx, apikey.QueryFilter{UserID: &other}); err != nil || total != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT list the keys of another user : got %d, %v.", failed, testID, total, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT list the keys of another user.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen handling an expired APIKey.", testID)
+		{
+			store, err := apikeydb.NewStore("")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to open the store: %v.", failed, testID, err)
+			}
+
+			core := apikey.NewCore(store, userLookup{}, auth.DefaultPolicy())
+			ctx := context.Background()
+			now := time.Now()
+			expires := now.Add(time.Hour)
+
+			nk := apikey.NewAPIKey{
+				Name:        "partner",
+				Roles:       []string{auth.RoleUser},
+				DateExpires: &expires,
+			}
+
+			_, secret, err := core.Create(ctx, claimsOf("45b5fbd3-755f-4379-8f07-a58d4a30fa2f", auth.RoleUser), nk, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create the key : %s.", failed, testID, err)
+			}
+
+			if _, err := core.Authenticate(ctx, secret, now.Add(2*time.Hour)); !errors.Is(err, apikey.ErrAuthenticationFailure) {
+				t.Fatalf("\t%s\tTest %d:\tShould reject an expired key : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject an expired key.", success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen scoping an APIKey to roles.", testID)
+		{
+			store, err := apikeydb.NewStore("")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to open the store: %v.", failed, testID, err)
+			}
+
+			const userID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+			users := userLookup{userID: {auth.RoleUser}}
+
+			core := apikey.NewCore(store, users, auth.DefaultPolicy())
+			ctx := context.Background()
+			now := time.Now()
+			claims := claimsOf(userID, auth.RoleUser)
+
+			nk := apikey.NewAPIKey{Name: "unknown", Roles: []string{"AUDITOR"}}
+			if _, _, err := core.Create(ctx, claims, nk, now); !validate.IsFieldErrors(err) {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a role the policy does not define : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject a role the policy does not define.", success, testID)
+
+			nk = apikey.NewAPIKey{Name: "escalate", Roles: []string{auth.RoleAdmin}}
+			if _, _, err := core.Create(ctx, claims, nk, now); !errors.Is(err, auth.ErrForbidden) {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a role the user does not hold : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject a role the user does not hold.", success, testID)
+
+			nk = apikey.NewAPIKey{Name: "owner", Roles: []string{auth.RoleUser}}
+			_, secret, err := core.Create(ctx, claims, nk, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create the key : %s.", failed, testID, err)
+			}
+
+			users[userID] = []string{auth.RoleAdmin}
+			if _, err := core.AuthenticateAPIKey(ctx, secret); !errors.Is(err, apikey.ErrAuthenticationFailure) {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the key once the owner loses its roles : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the key once the owner loses its roles.", success, testID)
+
+			delete(users, userID)
+			if _, err := core.AuthenticateAPIKey(ctx, secret); !errors.Is(err, apikey.ErrAuthenticationFailure) {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the key once the owner is deleted : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the key once the owner is deleted.", success, testID)
+		}
+	}
+}
+
+// claimsOf returns the claims of the user creating a key.
+func claimsOf(userID string, roles ...string) auth.Claims {
+	claims := auth.Claims{Roles: roles}
+	claims.Subject = userID
+	return claims
+}
+
+// userLookup holds the current roles of each user.
+type userLookup map[string][]string
+
+func (ul userLookup) UserRoles(ctx context.Context, userID string) ([]string, error) {
+	roles, ok := ul[userID]
+	if !ok {
+		return nil, auth.ErrUserNotFound
+	}
+	return roles, nil
+}
@@ -0,0 +1,16 @@
+package apikey
+
+import (
+	"github.com/ardanlabs/service/business/sys/validate"
+)
+
+// QueryFilter holds the available fields a query can be filtered on. A nil
+// field is not used in the filter.
+type QueryFilter struct {
+	UserID *string `json:"userID" validate:"omitempty,uuid"`
+}
+
+// Validate checks the data in the model is considered clean.
+func (qf QueryFilter) Validate() error {
+	return validate.Check(qf)
+}
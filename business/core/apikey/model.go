@@ -0,0 +1,46 @@
+package apikey
+
+import (
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/validate"
+)
+
+// APIKey represents a long lived key a client authenticates with. Only the
+// hash of the key is kept, the key itself is returned once when created.
+type APIKey struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	Hash        string     `json:"-"`
+	UserID      string     `json:"userID"`
+	Roles       []string   `json:"roles"`
+	DateCreated time.Time  `json:"dateCreated"`
+	DateExpires *time.Time `json:"dateExpires,omitempty"`
+	DateRevoked *time.Time `json:"dateRevoked,omitempty"`
+}
+
+// Active reports if the key can be used at the specified time.
+func (k APIKey) Active(now time.Time) bool {
+	if k.DateRevoked != nil {
+		return false
+	}
+	if k.DateExpires != nil && !now.Before(*k.DateExpires) {
+		return false
+	}
+	return true
+}
+
+// NewAPIKey contains information needed to create a new APIKey. The roles
+// scope what the key is allowed to do, they must be defined by the policy
+// and held by the user creating the key.
+type NewAPIKey struct {
+	Name        string     `json:"name" validate:"required"`
+	Roles       []string   `json:"roles" validate:"required"`
+	DateExpires *time.Time `json:"dateExpires"`
+}
+
+// Validate checks the data in the model is considered clean.
+func (nk NewAPIKey) Validate() error {
+	return validate.Check(nk)
+}
@@ -0,0 +1,231 @@
+// Package apikeydb contains api key related CRUD functionality backed by an
+// in-process store. Data lives in memory and, when a path is provided, is
+// persisted to a single JSON file on every write.
+package apikeydb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/ardanlabs/service/business/core/apikey"
+)
+
+// Store manages the set of APIs for api key database access.
+type Store struct {
+	mu     sync.RWMutex
+	path   string
+	keys   map[string]dbAPIKey
+	hashes map[string]string
+}
+
+// NewStore constructs the api for data access. An empty path keeps all data
+// in memory for the life of the process.
+func NewStore(path string) (*Store, error) {
+	s := Store{
+		path:   path,
+		keys:   make(map[string]dbAPIKey),
+		hashes: make(map[string]string),
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// Create inserts a new api key into the database.
+func (s *Store) Create(ctx context.Context, key apikey.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.keys[key.ID]; exists {
+		return fmt.Errorf("inserting api key: id %q already exists", key.ID)
+	}
+	if _, exists := s.hashes[key.Hash]; exists {
+		return fmt.Errorf("inserting api key: hash already exists")
+	}
+
+	s.keys[key.ID] = toDBAPIKey(key)
+	s.hashes[key.Hash] = key.ID
+
+	return s.save()
+}
+
+// Update replaces an api key document in the database. The hash of a key
+// can't change.
+func (s *Store) Update(ctx context.Context, key apikey.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dbKey, exists := s.keys[key.ID]
+	if !exists {
+		return apikey.ErrNotFound
+	}
+	if dbKey.Hash != key.Hash {
+		return fmt.Errorf("updating api key: hash can't change")
+	}
+
+	s.keys[key.ID] = toDBAPIKey(key)
+
+	return s.save()
+}
+
+// Query retrieves a list of existing api keys from the database.
+func (s *Store) Query(ctx context.Context, filter apikey.QueryFilter, orderBy apikey.OrderBy, pageNumber int, rowsPerPage int) ([]apikey.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	less, err := orderByFunc(orderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	dbKeys := s.filter(filter)
+	sort.SliceStable(dbKeys, func(i, j int) bool {
+		return less(dbKeys[i], dbKeys[j])
+	})
+
+	offset := (pageNumber - 1) * rowsPerPage
+	if offset < 0 || offset >= len(dbKeys) {
+		return []apikey.APIKey{}, nil
+	}
+
+	end := offset + rowsPerPage
+	if end > len(dbKeys) {
+		end = len(dbKeys)
+	}
+
+	keys := make([]apikey.APIKey, 0, end-offset)
+	for _, dbKey := range dbKeys[offset:end] {
+		keys = append(keys, toCoreAPIKey(dbKey))
+	}
+
+	return keys, nil
+}
+
+// Count returns the total number of api keys in the DB matching the filter.
+func (s *Store) Count(ctx context.Context, filter apikey.QueryFilter) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.filter(filter)), nil
+}
+
+// QueryByID gets the specified api key from the database.
+func (s *Store) QueryByID(ctx context.Context, keyID string) (apikey.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	dbKey, exists := s.keys[keyID]
+	if !exists {
+		return apikey.APIKey{}, apikey.ErrNotFound
+	}
+
+	return toCoreAPIKey(dbKey), nil
+}
+
+// QueryByHash gets the api key with the specified hash from the database.
+func (s *Store) QueryByHash(ctx context.Context, hash string) (apikey.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keyID, exists := s.hashes[hash]
+	if !exists {
+		return apikey.APIKey{}, apikey.ErrNotFound
+	}
+
+	return toCoreAPIKey(s.keys[keyID]), nil
+}
+
+// =============================================================================
+
+// filter returns the api keys matching every field set in the filter,
+// ordered by id. The caller must hold the lock.
+func (s *Store) filter(filter apikey.QueryFilter) []dbAPIKey {
+	dbKeys := make([]dbAPIKey, 0, len(s.keys))
+
+	for _, dbKey := range s.keys {
+		if filter.UserID != nil && dbKey.UserID != *filter.UserID {
+			continue
+		}
+		dbKeys = append(dbKeys, dbKey)
+	}
+
+	sort.Slice(dbKeys, func(i, j int) bool {
+		return dbKeys[i].ID < dbKeys[j].ID
+	})
+
+	return dbKeys
+}
+
+// load reads the api keys from the database file, if one exists.
+func (s *Store) load() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("reading database file: %w", err)
+	}
+
+	var dbKeys []dbAPIKey
+	if err := json.Unmarshal(data, &dbKeys); err != nil {
+		return fmt.Errorf("decoding database file: %w", err)
+	}
+
+	for _, dbKey := range dbKeys {
+		s.keys[dbKey.ID] = dbKey
+		s.hashes[dbKey.Hash] = dbKey.ID
+	}
+
+	return nil
+}
+
+// save writes the current set of api keys to the database file. The data is
+// written to a temporary file first and renamed so a crash never leaves a
+// partially written file behind. The caller must hold the lock.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	dbKeys := make([]dbAPIKey, 0, len(s.keys))
+	for _, dbKey := range s.keys {
+		dbKeys = append(dbKeys, dbKey)
+	}
+	sort.Slice(dbKeys, func(i, j int) bool {
+		return dbKeys[i].ID < dbKeys[j].ID
+	})
+
+	data, err := json.MarshalIndent(dbKeys, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding database file: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("creating database directory: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing database file: %w", err)
+	}
+
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("replacing database file: %w", err)
+	}
+
+	return nil
+}
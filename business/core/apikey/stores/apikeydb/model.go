@@ -0,0 +1,58 @@
+package apikeydb
+
+import (
+	"time"
+
+	"github.com/ardanlabs/service/business/core/apikey"
+)
+
+// dbAPIKey represent the structure we need for persisting an api key.
+type dbAPIKey struct {
+	ID          string     `json:"api_key_id"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	Hash        string     `json:"hash"`
+	UserID      string     `json:"user_id"`
+	Roles       []string   `json:"roles"`
+	DateCreated time.Time  `json:"date_created"`
+	DateExpires *time.Time `json:"date_expires,omitempty"`
+	DateRevoked *time.Time `json:"date_revoked,omitempty"`
+}
+
+func toDBAPIKey(key apikey.APIKey) dbAPIKey {
+	return dbAPIKey{
+		ID:          key.ID,
+		Name:        key.Name,
+		Prefix:      key.Prefix,
+		Hash:        key.Hash,
+		UserID:      key.UserID,
+		Roles:       append([]string(nil), key.Roles...),
+		DateCreated: key.DateCreated.UTC(),
+		DateExpires: utcTime(key.DateExpires),
+		DateRevoked: utcTime(key.DateRevoked),
+	}
+}
+
+func toCoreAPIKey(dbKey dbAPIKey) apikey.APIKey {
+	return apikey.APIKey{
+		ID:          dbKey.ID,
+		Name:        dbKey.Name,
+		Prefix:      dbKey.Prefix,
+		Hash:        dbKey.Hash,
+		UserID:      dbKey.UserID,
+		Roles:       append([]string(nil), dbKey.Roles...),
+		DateCreated: dbKey.DateCreated,
+		DateExpires: utcTime(dbKey.DateExpires),
+		DateRevoked: utcTime(dbKey.DateRevoked),
+	}
+}
+
+// utcTime returns a copy of the time in UTC so the caller can't modify the
+// stored value.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
@@ -0,0 +1,31 @@
+package apikeydb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ardanlabs/service/business/core/apikey"
+)
+
+// orderByFunc returns a less function that sorts api keys by the requested
+// field and direction.
+func orderByFunc(orderBy apikey.OrderBy) (func(a, b dbAPIKey) bool, error) {
+	var less func(a, b dbAPIKey) bool
+
+	switch orderBy.Field {
+	case apikey.OrderByID:
+		less = func(a, b dbAPIKey) bool { return a.ID < b.ID }
+	case apikey.OrderByName:
+		less = func(a, b dbAPIKey) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) }
+	case apikey.OrderByDateCreated:
+		less = func(a, b dbAPIKey) bool { return a.DateCreated.Before(b.DateCreated) }
+	default:
+		return nil, fmt.Errorf("field %q does not exist", orderBy.Field)
+	}
+
+	if orderBy.Descending {
+		return func(a, b dbAPIKey) bool { return less(b, a) }, nil
+	}
+
+	return less, nil
+}
@@ -0,0 +1,19 @@
+package user
+
+import (
+	"github.com/ardanlabs/service/business/sys/validate"
+)
+
+// QueryFilter holds the available fields a query can be filtered on. A nil
+// field is not used in the filter.
+type QueryFilter struct {
+	ID    *string `validate:"omitempty,uuid"`
+	Name  *string `validate:"omitempty,min=1"`
+	Email *string `validate:"omitempty,email"`
+	Role  *string
+}
+
+// Validate checks the data in the model is considered clean.
+func (qf QueryFilter) Validate() error {
+	return validate.Check(qf)
+}
@@ -0,0 +1,52 @@
+package user
+
+import (
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/validate"
+)
+
+// User represents an individual user.
+type User struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Email        string    `json:"email"`
+	Roles        []string  `json:"roles"`
+	PasswordHash []byte    `json:"-"`
+	Department   string    `json:"department"`
+	DateCreated  time.Time `json:"dateCreated"`
+	DateUpdated  time.Time `json:"dateUpdated"`
+}
+
+// NewUser contains information needed to create a new User.
+type NewUser struct {
+	Name            string   `json:"name" validate:"required"`
+	Email           string   `json:"email" validate:"required,email"`
+	Roles           []string `json:"roles" validate:"required"`
+	Department      string   `json:"department"`
+	Password        string   `json:"password" validate:"required"`
+	PasswordConfirm string   `json:"passwordConfirm" validate:"eqfield=Password"`
+}
+
+// Validate checks the data in the model is considered clean.
+func (nu NewUser) Validate() error {
+	return validate.Check(nu)
+}
+
+// UpdateUser defines what information may be provided to modify an existing
+// User. All fields are optional so clients can send just the fields they want
+// changed. It uses pointer fields so we can differentiate between a field that
+// was not provided and a field that was provided as explicitly blank.
+type UpdateUser struct {
+	Name            *string  `json:"name" validate:"omitempty,min=1"`
+	Email           *string  `json:"email" validate:"omitempty,email"`
+	Roles           []string `json:"roles"`
+	Department      *string  `json:"department"`
+	Password        *string  `json:"password"`
+	PasswordConfirm *string  `json:"passwordConfirm" validate:"eqfield=Password"`
+}
+
+// Validate checks the data in the model is considered clean.
+func (uu UpdateUser) Validate() error {
+	return validate.Check(uu)
+}
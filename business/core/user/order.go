@@ -0,0 +1,19 @@
+package user
+
+// Set of fields that the results can be ordered by. These are the names
+// that should be used by the application layer.
+const (
+	OrderByID          = "user_id"
+	OrderByName        = "name"
+	OrderByEmail       = "email"
+	OrderByDateCreated = "date_created"
+)
+
+// OrderBy represents a field used to order by and direction.
+type OrderBy struct {
+	Field      string
+	Descending bool
+}
+
+// DefaultOrderBy represents the default way we sort.
+var DefaultOrderBy = OrderBy{Field: OrderByID}
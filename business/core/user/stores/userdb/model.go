@@ -0,0 +1,45 @@
+package userdb
+
+import (
+	"time"
+
+	"github.com/ardanlabs/service/business/core/user"
+)
+
+// dbUser represent the structure we need for persisting a user.
+type dbUser struct {
+	ID           string    `json:"user_id"`
+	Name         string    `json:"name"`
+	Email        string    `json:"email"`
+	Roles        []string  `json:"roles"`
+	PasswordHash []byte    `json:"password_hash"`
+	Department   string    `json:"department"`
+	DateCreated  time.Time `json:"date_created"`
+	DateUpdated  time.Time `json:"date_updated"`
+}
+
+func toDBUser(usr user.User) dbUser {
+	return dbUser{
+		ID:           usr.ID,
+		Name:         usr.Name,
+		Email:        usr.Email,
+		Roles:        append([]string(nil), usr.Roles...),
+		PasswordHash: append([]byte(nil), usr.PasswordHash...),
+		Department:   usr.Department,
+		DateCreated:  usr.DateCreated.UTC(),
+		DateUpdated:  usr.DateUpdated.UTC(),
+	}
+}
+
+func toCoreUser(dbUsr dbUser) user.User {
+	return user.User{
+		ID:           dbUsr.ID,
+		Name:         dbUsr.Name,
+		Email:        dbUsr.Email,
+		Roles:        append([]string(nil), dbUsr.Roles...),
+		PasswordHash: append([]byte(nil), dbUsr.PasswordHash...),
+		Department:   dbUsr.Department,
+		DateCreated:  dbUsr.DateCreated,
+		DateUpdated:  dbUsr.DateUpdated,
+	}
+}
@@ -0,0 +1,33 @@
+package userdb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ardanlabs/service/business/core/user"
+)
+
+// orderByFunc returns a less function that sorts users by the requested
+// field and direction.
+func orderByFunc(orderBy user.OrderBy) (func(a, b dbUser) bool, error) {
+	var less func(a, b dbUser) bool
+
+	switch orderBy.Field {
+	case user.OrderByID:
+		less = func(a, b dbUser) bool { return a.ID < b.ID }
+	case user.OrderByName:
+		less = func(a, b dbUser) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) }
+	case user.OrderByEmail:
+		less = func(a, b dbUser) bool { return strings.ToLower(a.Email) < strings.ToLower(b.Email) }
+	case user.OrderByDateCreated:
+		less = func(a, b dbUser) bool { return a.DateCreated.Before(b.DateCreated) }
+	default:
+		return nil, fmt.Errorf("field %q does not exist", orderBy.Field)
+	}
+
+	if orderBy.Descending {
+		return func(a, b dbUser) bool { return less(b, a) }, nil
+	}
+
+	return less, nil
+}
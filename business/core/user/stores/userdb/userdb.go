@@ -0,0 +1,316 @@
+// Package userdb contains user related CRUD functionality backed by an
+// in-process store. Data lives in memory and, when a path is provided, is
+// persisted to a single JSON file on every write.
+package userdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ardanlabs/service/business/core/user"
+)
+
+// Store manages the set of APIs for user database access.
+type Store struct {
+	mu    sync.RWMutex
+	path  string
+	users map[string]dbUser
+}
+
+// NewStore constructs the api for data access. An empty path keeps all data
+// in memory for the life of the process.
+func NewStore(path string) (*Store, error) {
+	s := Store{
+		path:  path,
+		users: make(map[string]dbUser),
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// Create inserts a new user into the database.
+func (s *Store) Create(ctx context.Context, usr user.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[usr.ID]; exists {
+		return fmt.Errorf("inserting user: id %q already exists", usr.ID)
+	}
+	if s.emailTaken(usr.Email, usr.ID) {
+		return user.ErrUniqueEmail
+	}
+
+	s.users[usr.ID] = toDBUser(usr)
+
+	if err := s.save(); err != nil {
+		delete(s.users, usr.ID)
+		return err
+	}
+
+	return nil
+}
+
+// Update replaces a user document in the database.
+func (s *Store) Update(ctx context.Context, usr user.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, exists := s.users[usr.ID]
+	if !exists {
+		return user.ErrNotFound
+	}
+	if s.emailTaken(usr.Email, usr.ID) {
+		return user.ErrUniqueEmail
+	}
+
+	s.users[usr.ID] = toDBUser(usr)
+
+	if err := s.save(); err != nil {
+		s.users[usr.ID] = old
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes a user from the database.
+func (s *Store) Delete(ctx context.Context, usr user.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, exists := s.users[usr.ID]
+	if !exists {
+		return user.ErrNotFound
+	}
+
+	delete(s.users, usr.ID)
+
+	if err := s.save(); err != nil {
+		s.users[usr.ID] = old
+		return err
+	}
+
+	return nil
+}
+
+// Query retrieves a list of existing users from the database.
+func (s *Store) Query(ctx context.Context, filter user.QueryFilter, orderBy user.OrderBy, pageNumber int, rowsPerPage int) ([]user.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	less, err := orderByFunc(orderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	dbUsrs := s.filter(filter)
+	sort.SliceStable(dbUsrs, func(i, j int) bool {
+		return less(dbUsrs[i], dbUsrs[j])
+	})
+
+	offset := (pageNumber - 1) * rowsPerPage
+	if offset < 0 || offset >= len(dbUsrs) {
+		return []user.User{}, nil
+	}
+
+	end := offset + rowsPerPage
+	if end > len(dbUsrs) {
+		end = len(dbUsrs)
+	}
+
+	users := make([]user.User, 0, end-offset)
+	for _, dbUsr := range dbUsrs[offset:end] {
+		users = append(users, toCoreUser(dbUsr))
+	}
+
+	return users, nil
+}
+
+// Count returns the total number of users in the DB matching the filter.
+func (s *Store) Count(ctx context.Context, filter user.QueryFilter) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.filter(filter)), nil
+}
+
+// QueryByID gets the specified user from the database.
+func (s *Store) QueryByID(ctx context.Context, userID string) (user.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	dbUsr, exists := s.users[userID]
+	if !exists {
+		return user.User{}, user.ErrNotFound
+	}
+
+	return toCoreUser(dbUsr), nil
+}
+
+// QueryByEmail gets the specified user from the database by email.
+func (s *Store) QueryByEmail(ctx context.Context, email string) (user.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, dbUsr := range s.users {
+		if strings.EqualFold(dbUsr.Email, email) {
+			return toCoreUser(dbUsr), nil
+		}
+	}
+
+	return user.User{}, user.ErrNotFound
+}
+
+// Migrate writes the database file in the current format, creating it when
+// it doesn't exist.
+func (s *Store) Migrate(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.save()
+}
+
+// Ping reports if the database file is reachable. A database that hasn't
+// been written to yet is considered healthy.
+func (s *Store) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if s.path == "" {
+		return nil
+	}
+
+	if _, err := os.Stat(s.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("checking database file: %w", err)
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// filter returns the users matching every field set in the filter, ordered
+// by id. The caller must hold the lock.
+func (s *Store) filter(filter user.QueryFilter) []dbUser {
+	dbUsrs := make([]dbUser, 0, len(s.users))
+
+	for _, dbUsr := range s.users {
+		if filter.ID != nil && dbUsr.ID != *filter.ID {
+			continue
+		}
+		if filter.Name != nil && !strings.Contains(strings.ToLower(dbUsr.Name), strings.ToLower(*filter.Name)) {
+			continue
+		}
+		if filter.Email != nil && !strings.EqualFold(dbUsr.Email, *filter.Email) {
+			continue
+		}
+		if filter.Role != nil && !hasRole(dbUsr.Roles, *filter.Role) {
+			continue
+		}
+		dbUsrs = append(dbUsrs, dbUsr)
+	}
+
+	sort.Slice(dbUsrs, func(i, j int) bool {
+		return dbUsrs[i].ID < dbUsrs[j].ID
+	})
+
+	return dbUsrs
+}
+
+// hasRole reports if the role is in the set of roles.
+func hasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// emailTaken reports if a user other than the specified one already owns
+// the email address. The caller must hold the lock.
+func (s *Store) emailTaken(email string, userID string) bool {
+	for id, dbUsr := range s.users {
+		if id != userID && strings.EqualFold(dbUsr.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// load reads the database file into memory if one exists.
+func (s *Store) load() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("reading database file: %w", err)
+	}
+
+	var users []dbUser
+	if err := json.Unmarshal(data, &users); err != nil {
+		return fmt.Errorf("decoding database file: %w", err)
+	}
+
+	for _, dbUsr := range users {
+		s.users[dbUsr.ID] = dbUsr
+	}
+
+	return nil
+}
+
+// save writes the current set of users to the database file. The data is
+// written to a temporary file first and renamed so a crash never leaves a
+// partially written file behind. The caller must hold the lock and undo its
+// change when save fails, so memory never holds what the file doesn't.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	users := make([]dbUser, 0, len(s.users))
+	for _, dbUsr := range s.users {
+		users = append(users, dbUsr)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+
+	data, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding database file: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("creating database directory: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing database file: %w", err)
+	}
+
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("replacing database file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,253 @@
+// Package user provides a core business API for managing users. Right now
+// these calls are mostly wrapping the data/store layer, but this is the place
+// for auditing or anything else that isn't specific to storage.
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Set of error variables for CRUD operations.
+var (
+	ErrNotFound              = errors.New("user not found")
+	ErrInvalidID             = errors.New("ID is not in its proper form")
+	ErrUniqueEmail           = errors.New("email is not unique")
+	ErrAuthenticationFailure = errors.New("authentication failed")
+)
+
+// Storer interface declares the behavior this package needs to persist and
+// retrieve data.
+type Storer interface {
+	Create(ctx context.Context, usr User) error
+	Update(ctx context.Context, usr User) error
+	Delete(ctx context.Context, usr User) error
+	Query(ctx context.Context, filter QueryFilter, orderBy OrderBy, pageNumber int, rowsPerPage int) ([]User, error)
+	Count(ctx context.Context, filter QueryFilter) (int, error)
+	QueryByID(ctx context.Context, userID string) (User, error)
+	QueryByEmail(ctx context.Context, email string) (User, error)
+}
+
+// Core manages the set of APIs for user access.
+type Core struct {
+	storer Storer
+	policy *auth.Policy
+}
+
+// NewCore constructs a core for user api access. The policy defines the
+// roles a user can hold.
+func NewCore(storer Storer, policy *auth.Policy) *Core {
+	return &Core{
+		storer: storer,
+		policy: policy,
+	}
+}
+
+// Create inserts a new user into the database.
+func (c *Core) Create(ctx context.Context, nu NewUser, now time.Time) (User, error) {
+	if err := c.checkRoles(nu.Validate(), "roles", nu.Roles); err != nil {
+		return User{}, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(nu.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return User{}, fmt.Errorf("generatefrompassword: %w", err)
+	}
+
+	usr := User{
+		ID:           uuid.New().String(),
+		Name:         nu.Name,
+		Email:        nu.Email,
+		PasswordHash: hash,
+		Roles:        nu.Roles,
+		Department:   nu.Department,
+		DateCreated:  now,
+		DateUpdated:  now,
+	}
+
+	if err := c.storer.Create(ctx, usr); err != nil {
+		return User{}, fmt.Errorf("create: %w", err)
+	}
+
+	return usr, nil
+}
+
+// Update replaces a user document in the database.
+func (c *Core) Update(ctx context.Context, userID string, uu UpdateUser, now time.Time) (User, error) {
+	if err := c.checkRoles(uu.Validate(), "roles", uu.Roles); err != nil {
+		return User{}, err
+	}
+
+	usr, err := c.QueryByID(ctx, userID)
+	if err != nil {
+		return User{}, fmt.Errorf("query: %w", err)
+	}
+
+	if uu.Name != nil {
+		usr.Name = *uu.Name
+	}
+	if uu.Email != nil {
+		usr.Email = *uu.Email
+	}
+	if uu.Roles != nil {
+		usr.Roles = uu.Roles
+	}
+	if uu.Department != nil {
+		usr.Department = *uu.Department
+	}
+	if uu.Password != nil {
+		pw, err := bcrypt.GenerateFromPassword([]byte(*uu.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return User{}, fmt.Errorf("generatefrompassword: %w", err)
+		}
+		usr.PasswordHash = pw
+	}
+	usr.DateUpdated = now
+
+	if err := c.storer.Update(ctx, usr); err != nil {
+		return User{}, fmt.Errorf("update: %w", err)
+	}
+
+	return usr, nil
+}
+
+// Delete removes a user from the database.
+func (c *Core) Delete(ctx context.Context, userID string) error {
+	usr, err := c.QueryByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("query: %w", err)
+	}
+
+	if err := c.storer.Delete(ctx, usr); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	return nil
+}
+
+// Query retrieves a list of existing users from the database.
+func (c *Core) Query(ctx context.Context, filter QueryFilter, orderBy OrderBy, pageNumber int, rowsPerPage int) ([]User, error) {
+	if err := c.checkFilter(filter); err != nil {
+		return nil, err
+	}
+
+	users, err := c.storer.Query(ctx, filter, orderBy, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return users, nil
+}
+
+// Count returns the total number of users matching the filter.
+func (c *Core) Count(ctx context.Context, filter QueryFilter) (int, error) {
+	if err := c.checkFilter(filter); err != nil {
+		return 0, err
+	}
+
+	total, err := c.storer.Count(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("count: %w", err)
+	}
+
+	return total, nil
+}
+
+// QueryByID gets the specified user from the database.
+func (c *Core) QueryByID(ctx context.Context, userID string) (User, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return User{}, ErrInvalidID
+	}
+
+	usr, err := c.storer.QueryByID(ctx, userID)
+	if err != nil {
+		return User{}, fmt.Errorf("query: %w", err)
+	}
+
+	return usr, nil
+}
+
+// UserRoles implements the auth.UserLookup interface so a refresh token only
+// keeps working while the user exists with the same roles.
+func (c *Core) UserRoles(ctx context.Context, userID string) ([]string, error) {
+	usr, err := c.QueryByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrInvalidID) {
+			return nil, auth.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return usr.Roles, nil
+}
+
+// QueryByEmail gets the specified user from the database by email.
+func (c *Core) QueryByEmail(ctx context.Context, email string) (User, error) {
+	usr, err := c.storer.QueryByEmail(ctx, email)
+	if err != nil {
+		return User{}, fmt.Errorf("query: %w", err)
+	}
+
+	return usr, nil
+}
+
+// Authenticate finds a user by their email and verifies their password. On
+// success it returns the user so the caller can build a set of claims. Both
+// an unknown email and a bad password report ErrAuthenticationFailure so the
+// caller can't be used to discover which emails exist.
+func (c *Core) Authenticate(ctx context.Context, email, password string) (User, error) {
+	usr, err := c.storer.QueryByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return User{}, ErrAuthenticationFailure
+		}
+		return User{}, fmt.Errorf("query: %w", err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword(usr.PasswordHash, []byte(password)); err != nil {
+		return User{}, ErrAuthenticationFailure
+	}
+
+	return usr, nil
+}
+
+// checkFilter validates the filter, including the role against the policy.
+func (c *Core) checkFilter(filter QueryFilter) error {
+	var roles []string
+	if filter.Role != nil {
+		roles = []string{*filter.Role}
+	}
+
+	return c.checkRoles(filter.Validate(), "role", roles)
+}
+
+// checkRoles adds an error for the field to the result of validating the
+// model when one of the roles isn't defined by the policy.
+func (c *Core) checkRoles(err error, field string, roles []string) error {
+	if err != nil && !validate.IsFieldErrors(err) {
+		return err
+	}
+
+	fields := validate.GetFieldErrors(err)
+	for _, role := range roles {
+		if !c.policy.HasRole(role) {
+			fields = append(fields, validate.FieldError{
+				Field: field,
+				Error: fmt.Sprintf("%s has %q which is not a defined role", field, role),
+			})
+			break
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
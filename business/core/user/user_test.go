@@ -0,0 +1,301 @@
+package user_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/user"
+	"github.com/ardanlabs/service/business/core/user/stores/userdb"
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/sys/validate"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_User(t *testing.T) {
+	t.Log("Given the need to work with User records.")
+
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a single User.", testID)
+		{
+			path := filepath.Join(t.TempDir(), "users.json")
+			store, err := userdb.NewStore(path)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to open the store: %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to open the store.", success, testID)
+
+			core := user.NewCore(store, auth.DefaultPolicy())
+			ctx := context.Background()
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+			nu := user.NewUser{
+				Name:            "Bill Kennedy",
+				Email:           "bill@ardanlabs.com",
+				Roles:           []string{auth.RoleAdmin},
+				Department:      "IT",
+				Password:        "gophers",
+				PasswordConfirm: "gophers",
+			}
+
+			usr, err := core.Create(ctx, nu, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create user : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create user.", success, testID)
+
+			saved, err := core.QueryByID(ctx, usr.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve user by ID: %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve user by ID.", success, testID)
+
+			if saved.Email != nu.Email || saved.Name != nu.Name {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, saved)
+				t.Logf("\t\tTest %d:\tExp: %v", testID, usr)
+				t.Fatalf("\t%s\tTest %d:\tShould get back the same user.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the same user.", success, testID)
+
+			if _, err := core.Create(ctx, nu, now); !errors.Is(err, user.ErrUniqueEmail) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to create a duplicate email : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to create a duplicate email.", success, testID)
+
+			role := auth.RoleAdmin
+			filter := user.QueryFilter{Role: &role}
+
+			users, err := core.Query(ctx, filter, user.DefaultOrderBy, 1, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query users by role : %s.", failed, testID, err)
+			}
+
+			total, err := core.Count(ctx, filter)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to count users by role : %s.", failed, testID, err)
+			}
+
+			if len(users) != 1 || total != 1 || users[0].ID != usr.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the one user in the role : %d/%d.", failed, testID, len(users), total)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the one user in the role.", success, testID)
+
+			name := "Jacob Walker"
+			upd := user.UpdateUser{
+				Name: &name,
+			}
+
+			if _, err := core.Update(ctx, usr.ID, upd, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update user : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to update user.", success, testID)
+
+			reopened, err := userdb.NewStore(path)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to reopen the store: %v.", failed, testID, err)
+			}
+
+			saved, err = user.NewCore(reopened, auth.DefaultPolicy()).QueryByEmail(ctx, nu.Email)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve user by Email from disk : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve user by Email from disk.", success, testID)
+
+			if saved.Name != name {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, saved.Name)
+				t.Logf("\t\tTest %d:\tExp: %v", testID, name)
+				t.Fatalf("\t%s\tTest %d:\tShould be able to see updates to Name.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to see updates to Name.", success, testID)
+
+			if _, err := core.Authenticate(ctx, nu.Email, nu.Password); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to authenticate with the password : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to authenticate with the password.", success, testID)
+
+			if _, err := core.Authenticate(ctx, nu.Email, "wrong"); !errors.Is(err, user.ErrAuthenticationFailure) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to authenticate with a bad password : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to authenticate with a bad password.", success, testID)
+
+			if roles, err := core.UserRoles(ctx, usr.ID); err != nil || len(roles) != 1 || roles[0] != auth.RoleAdmin {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to look up the roles of the user : %v, %s.", failed, testID, roles, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to look up the roles of the user.", success, testID)
+
+			if err := core.Delete(ctx, usr.ID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete user : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to delete user.", success, testID)
+
+			if _, err := core.QueryByID(ctx, usr.ID); !errors.Is(err, user.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve user : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve user.", success, testID)
+
+			if _, err := core.UserRoles(ctx, usr.ID); !errors.Is(err, auth.ErrUserNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to look up the roles of the user : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to look up the roles of the user.", success, testID)
+		}
+	}
+
+	{
+		testID := 1
+		t.Logf("\tTest %d:\tWhen handling bad User data.", testID)
+		{
+			store, err := userdb.NewStore("")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to open the store: %v.", failed, testID, err)
+			}
+
+			nu := user.NewUser{
+				Email:           "not an email",
+				Roles:           []string{"SUPERUSER"},
+				Password:        "gophers",
+				PasswordConfirm: "gopher",
+			}
+
+			_, err = user.NewCore(store, auth.DefaultPolicy()).Create(context.Background(), nu, time.Now())
+			if !validate.IsFieldErrors(err) {
+				t.Fatalf("\t%s\tTest %d:\tShould get back field errors : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back field errors.", success, testID)
+
+			fields := validate.GetFieldErrors(err).Fields()
+			for _, field := range []string{"name", "email", "roles", "passwordConfirm"} {
+				if _, exists := fields[field]; !exists {
+					t.Fatalf("\t%s\tTest %d:\tShould have an error for field %q : %v.", failed, testID, field, fields)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould have an error for every bad field.", success, testID)
+		}
+	}
+	{
+		testID := 2
+		t.Logf("\tTest %d:\tWhen the database file can't be written.", testID)
+		{
+			path := filepath.Join(t.TempDir(), "users.json")
+			store, err := userdb.NewStore(path)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to open the store: %v.", failed, testID, err)
+			}
+
+			core := user.NewCore(store, auth.DefaultPolicy())
+			ctx := context.Background()
+			now := time.Now()
+
+			nu := user.NewUser{
+				Name:            "Bill Kennedy",
+				Email:           "bill@ardanlabs.com",
+				Roles:           []string{auth.RoleUser},
+				Password:        "gophers",
+				PasswordConfirm: "gophers",
+			}
+
+			usr, err := core.Create(ctx, nu, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create user : %s.", failed, testID, err)
+			}
+
+			// A directory in the way of the temporary file fails every save.
+			if err := os.Mkdir(path+".tmp", 0700); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to block the database file : %s.", failed, testID, err)
+			}
+
+			name := "Jacob Walker"
+			if _, err := core.Update(ctx, usr.ID, user.UpdateUser{Name: &name}, now); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to update user.", failed, testID)
+			}
+			if saved, err := core.QueryByID(ctx, usr.ID); err != nil || saved.Name != nu.Name {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the user as it was : %v, %s.", failed, testID, saved.Name, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the user as it was after a failed update.", success, testID)
+
+			if err := core.Delete(ctx, usr.ID); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to delete user.", failed, testID)
+			}
+			if _, err := core.QueryByID(ctx, usr.ID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the user after a failed delete : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the user after a failed delete.", success, testID)
+
+			nu.Email = "jacob@ardanlabs.com"
+			if _, err := core.Create(ctx, nu, now); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to create user.", failed, testID)
+			}
+			if _, err := core.QueryByEmail(ctx, nu.Email); !errors.Is(err, user.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT keep the user after a failed create : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT keep the user after a failed create.", success, testID)
+		}
+	}
+
+	{
+		testID := 3
+		t.Logf("\tTest %d:\tWhen the policy defines its own roles.", testID)
+		{
+			store, err := userdb.NewStore("")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to open the store: %v.", failed, testID, err)
+			}
+
+			policy, err := auth.NewPolicy(map[string][]string{
+				auth.RoleAdmin: {"*"},
+				"SUPPORT":      {auth.PermUsersRead},
+			})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to build the policy : %s.", failed, testID, err)
+			}
+
+			core := user.NewCore(store, policy)
+			ctx := context.Background()
+
+			nu := user.NewUser{
+				Name:            "Bill Kennedy",
+				Email:           "bill@ardanlabs.com",
+				Roles:           []string{"SUPPORT"},
+				Password:        "gophers",
+				PasswordConfirm: "gophers",
+			}
+
+			usr, err := core.Create(ctx, nu, time.Now())
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a user with a role of the policy : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a user with a role of the policy.", success, testID)
+
+			role := "SUPPORT"
+			if total, err := core.Count(ctx, user.QueryFilter{Role: &role}); err != nil || total != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to count users by a role of the policy : %d, %s.", failed, testID, total, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to count users by a role of the policy.", success, testID)
+
+			nu.Email = "jacob@ardanlabs.com"
+			nu.Roles = []string{auth.RoleUser}
+			if _, err := core.Create(ctx, nu, time.Now()); validate.GetFieldErrors(err).Fields()["roles"] == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to create a user with a role outside the policy : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to create a user with a role outside the policy.", success, testID)
+
+			if _, err := core.Update(ctx, usr.ID, user.UpdateUser{Roles: []string{auth.RoleUser}}, time.Now()); validate.GetFieldErrors(err).Fields()["roles"] == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to give a user a role outside the policy : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to give a user a role outside the policy.", success, testID)
+
+			role = auth.RoleUser
+			if _, err := core.Query(ctx, user.QueryFilter{Role: &role}, user.DefaultOrderBy, 1, 10); validate.GetFieldErrors(err).Fields()["role"] == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to query users by a role outside the policy : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to query users by a role outside the policy.", success, testID)
+		}
+	}
+}
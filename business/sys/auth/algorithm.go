@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"fmt"
+)
+
+// Set of signing algorithms that are supported.
+const (
+	AlgRS256 = "RS256"
+	AlgPS256 = "PS256"
+	AlgES256 = "ES256"
+	AlgES384 = "ES384"
+	AlgEdDSA = "EdDSA"
+)
+
+// algorithms is the list of algorithms a token may be signed with.
+var algorithms = []string{AlgRS256, AlgPS256, AlgES256, AlgES384, AlgEdDSA}
+
+// AlgorithmLookup is implemented by a KeyLookup that records which algorithm
+// each key signs with. Without it, the algorithm is derived from the type of
+// the key. An empty algorithm means any algorithm the key supports.
+type AlgorithmLookup interface {
+	Algorithm(kid string) (string, error)
+}
+
+// DefaultAlgorithm returns the algorithm used with a key when one isn't
+// specified. RSA keys default to RS256.
+func DefaultAlgorithm(publicKey crypto.PublicKey) (string, error) {
+	switch pk := publicKey.(type) {
+	case *rsa.PublicKey:
+		return AlgRS256, nil
+
+	case *ecdsa.PublicKey:
+		switch pk.Curve {
+		case elliptic.P256():
+			return AlgES256, nil
+		case elliptic.P384():
+			return AlgES384, nil
+		}
+		return "", fmt.Errorf("unsupported curve %s", pk.Curve.Params().Name)
+
+	case ed25519.PublicKey:
+		return AlgEdDSA, nil
+	}
+
+	return "", fmt.Errorf("unsupported key type %T", publicKey)
+}
+
+// CheckAlgorithm validates that the key can be used with the algorithm.
+func CheckAlgorithm(algorithm string, publicKey crypto.PublicKey) error {
+	ok := false
+
+	switch pk := publicKey.(type) {
+	case *rsa.PublicKey:
+		ok = algorithm == AlgRS256 || algorithm == AlgPS256
+
+	case *ecdsa.PublicKey:
+		switch algorithm {
+		case AlgES256:
+			ok = pk.Curve == elliptic.P256()
+		case AlgES384:
+			ok = pk.Curve == elliptic.P384()
+		}
+
+	case ed25519.PublicKey:
+		ok = algorithm == AlgEdDSA
+	}
+
+	if !ok {
+		return fmt.Errorf("algorithm %q can't be used with key type %T", algorithm, publicKey)
+	}
+
+	return nil
+}
+
+// algorithm returns the algorithm the key identified by the kid signs with.
+// An empty string is returned when the key lookup doesn't restrict it.
+func algorithm(keyLookup KeyLookup, kid string) (string, error) {
+	al, ok := keyLookup.(AlgorithmLookup)
+	if !ok {
+		return "", nil
+	}
+	return al.Algorithm(kid)
+}
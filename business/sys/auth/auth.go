@@ -2,20 +2,30 @@
 package auth
 
 import (
-	"crypto/rsa"
+	"crypto"
 	"errors"
 	"fmt"
+
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 )
 
-//// ErrForbidden is returned when a auth issue is identified.
-//var ErrForbidden = errors.New("attempted action is not allowed")
+// ErrForbidden is returned when a auth issue is identified.
+var ErrForbidden = errors.New("attempted action is not allowed")
 
 // KeyLookup declares a method set of behavior for looking up
-// private and public keys for JWT use.
+// private and public keys for JWT use. The keys may be RSA, ECDSA or
+// Ed25519 keys.
 type KeyLookup interface {
-	PrivateKeyPEM(kid string) (*rsa.PrivateKey, error)
-	PublicKeyPEM(kid string) (*rsa.PublicKey, error)
+	PrivateKeyPEM(kid string) (crypto.Signer, error)
+	PublicKeyPEM(kid string) (crypto.PublicKey, error)
+}
+
+// ActiveKIDLookup is implemented by a KeyLookup that supports key rotation.
+// When available, it decides which key signs new tokens instead of the kid
+// provided to New.
+type ActiveKIDLookup interface {
+	ActiveKID() (string, error)
 }
 
 // Auth is used to authenticate clients. It can generate a token for a
@@ -23,63 +33,160 @@ type KeyLookup interface {
 type Auth struct {
 	activeKID string
 	keyLookup KeyLookup
-	method    jwt.SigningMethod
 	parser    *jwt.Parser
 	keyFunc   func(t *jwt.Token) (interface{}, error)
-	cache     map[string]string
+	opts      options
 }
 
-func New(activeKID string, keyLookup KeyLookup) (*Auth, error) {
+// New creates an Auth to support authentication/authorization. The activeKID
+// is the key used to sign tokens unless the keyLookup supports rotation
+// through the ActiveKIDLookup interface. The options declare the rules the
+// claims of a token must pass on top of its signature.
+func New(activeKID string, keyLookup KeyLookup, opts ...Option) (*Auth, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
 
-	// The active KID represents the private key used to signed new tokens
-	_, err := keyLookup.PrivateKeyPEM(activeKID)
-	if err != nil {
-		return nil, errors.New("configuring algorith RS256")
+	if o.policy == nil {
+		o.policy = DefaultPolicy()
 	}
 
-	method := jwt.GetSigningMethod("RS256")
-	if method == nil {
-		return nil, errors.New("configuring algorith RS256")
+	for _, claim := range o.requiredClaims {
+		switch claim {
+		case ClaimSubject, ClaimExpiresAt, ClaimIssuedAt, ClaimNotBefore, ClaimIssuer, ClaimAudience, ClaimID:
+		default:
+			return nil, fmt.Errorf("unknown required claim %q", claim)
+		}
+	}
+
+	if akl, ok := keyLookup.(ActiveKIDLookup); ok {
+		kid, err := akl.ActiveKID()
+		if err != nil {
+			return nil, fmt.Errorf("active kid: %w", err)
+		}
+		activeKID = kid
 	}
 
-	keyFunc := func(t *jwt.Token) (interface{}, error){
+	// The active KID represents the private key used to signed new tokens
+	if _, _, err := signingKey(keyLookup, activeKID); err != nil {
+		return nil, fmt.Errorf("active kid %q: %w", activeKID, err)
+	}
+
+	// The algorithm in the token header must be one the key is configured
+	// for, so a token can't pick how its signature is checked.
+	keyFunc := func(t *jwt.Token) (interface{}, error) {
 		kid, ok := t.Header["kid"]
 		if !ok {
 			return nil, errors.New("missing key id (kid) in token header")
 		}
 		kidID, ok := kid.(string)
 		if !ok {
-			return keyLookup.PublicKeyPEM(kidID)
+			return nil, errors.New("user token key id (kid) must be string")
+		}
+
+		// The claims are decoded before the key is looked up, the issuer
+		// decides which keys may have signed the token.
+		verifier := keyLookup
+		if claims, ok := t.Claims.(*Claims); ok {
+			if kl, ok := o.issuerKeys[claims.Issuer]; ok {
+				verifier = kl
+			}
+		}
+
+		publicKey, err := verifier.PublicKeyPEM(kidID)
+		if err != nil {
+			return nil, err
+		}
+
+		alg, err := algorithm(verifier, kidID)
+		if err != nil {
+			return nil, err
+		}
+		if alg != "" && alg != t.Method.Alg() {
+			return nil, fmt.Errorf("key %q does not sign with %s", kidID, t.Method.Alg())
+		}
+
+		if err := CheckAlgorithm(t.Method.Alg(), publicKey); err != nil {
+			return nil, err
 		}
-		return kidID, nil
+
+		return publicKey, nil
 	}
-	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256"}))
+	// The claims are validated by ValidateToken so the leeway applies.
+	parser := jwt.NewParser(jwt.WithValidMethods(algorithms), jwt.WithoutClaimsValidation())
 
-	a:= Auth{
+	a := Auth{
 		activeKID: activeKID,
 		keyLookup: keyLookup,
-		method: method,
-		keyFunc: keyFunc,
-		parser: parser,
+		keyFunc:   keyFunc,
+		parser:    parser,
+		opts:      o,
 	}
 
 	return &a, nil
 }
 
 // GenerateToken generates a signed JWT token string representing the user Claims.
+// The token is signed with the currently active key using its algorithm. A
+// unique token id (jti) is set when the claims don't carry one so the token
+// can be revoked.
 func (a *Auth) GenerateToken(claims Claims) (string, error) {
-	token := jwt.NewWithClaims(a.method, claims)
-	token.Header["kid"] = a.activeKID
+	if claims.ID == "" {
+		claims.ID = uuid.New().String()
+	}
+
+	kid := a.activeKID
+	if akl, ok := a.keyLookup.(ActiveKIDLookup); ok {
+		var err error
+		if kid, err = akl.ActiveKID(); err != nil {
+			return "", fmt.Errorf("active kid: %w", err)
+		}
+	}
 
-	privateKey, err := a.keyLookup.PrivateKeyPEM(a.activeKID)
+	signer, method, err := signingKey(a.keyLookup, kid)
 	if err != nil {
 		return "", fmt.Errorf("private key: %w", err)
 	}
 
-	str, err := token.SignedString(privateKey)
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+
+	str, err := token.SignedString(signer)
 	if err != nil {
 		return "", fmt.Errorf("signing token: %w", err)
 	}
 
 	return str, nil
 }
+
+// signingKey returns the private key for the kid and the signing method
+// to use with it.
+func signingKey(keyLookup KeyLookup, kid string) (crypto.Signer, jwt.SigningMethod, error) {
+	signer, err := keyLookup.PrivateKeyPEM(kid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	alg, err := algorithm(keyLookup, kid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if alg == "" {
+		if alg, err = DefaultAlgorithm(signer.Public()); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := CheckAlgorithm(alg, signer.Public()); err != nil {
+		return nil, nil, err
+	}
+
+	method := jwt.GetSigningMethod(alg)
+	if method == nil {
+		return nil, nil, fmt.Errorf("configuring algorithm %s", alg)
+	}
+
+	return signer, method, nil
+}
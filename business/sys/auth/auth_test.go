@@ -1,9 +1,15 @@
 package auth_test
 
 import (
+	"context"
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
+	"errors"
+	"path/filepath"
 	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/business/sys/auth/stores/refreshdb"
+	"github.com/ardanlabs/service/business/sys/auth/stores/revokedb"
 	"github.com/golang-jwt/jwt/v4"
 	"testing"
 	"time"
@@ -49,7 +55,7 @@ func Test_Auth(t *testing.T) {
 			}
 			t.Logf("\t%s\tTest %d:\tShould be able to generate a JWT.", success, testID)
 
-			parsedClaims, err := a.ValidateToken(token)
+			parsedClaims, err := a.ValidateToken(context.Background(), token)
 			if err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to parse the claims: %v", failed, testID, err)
 			}
@@ -78,11 +84,390 @@ type keyStore struct{
 	pk *rsa.PrivateKey
 }
 
-func (ks *keyStore) PrivateKeyPEM(kid string) (*rsa.PrivateKey, error) {
+func (ks *keyStore) PrivateKeyPEM(kid string) (crypto.Signer, error) {
 	return ks.pk, nil
 }
 
-func (ks *keyStore) PublicKeyPEM(kid string) (*rsa.PublicKey, error) {
+func (ks *keyStore) PublicKeyPEM(kid string) (crypto.PublicKey, error) {
 	return &ks.pk.PublicKey, nil
 }
 
+
+func Test_Claims(t *testing.T) {
+	t.Log("Given the need to validate the claims of a token.")
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create a private key: %v.", failed, err)
+	}
+
+	a, err := auth.New("54bb2165-71e1-41a6-af3e-7da4a0e1e2c1", &keyStore{pk: privateKey},
+		auth.WithIssuer("service project"),
+		auth.WithAudiences("sales-api"),
+		auth.WithLeeway(time.Minute),
+		auth.WithMaxAge(24*time.Hour),
+		auth.WithRequiredClaims(auth.ClaimSubject, auth.ClaimExpiresAt),
+	)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create authenticator: %v", failed, err)
+	}
+
+	now := time.Now()
+	valid := func() jwt.RegisteredClaims {
+		return jwt.RegisteredClaims{
+			Issuer:    "service project",
+			Subject:   "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1",
+			Audience:  jwt.ClaimStrings{"other", "sales-api"},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		}
+	}
+
+	tt := []struct {
+		name   string
+		modify func(rc *jwt.RegisteredClaims)
+		err    error
+	}{
+		{"valid claims", func(rc *jwt.RegisteredClaims) {}, nil},
+		{"expiry within the leeway", func(rc *jwt.RegisteredClaims) { rc.ExpiresAt = jwt.NewNumericDate(now.Add(-30 * time.Second)) }, nil},
+		{"expired claims", func(rc *jwt.RegisteredClaims) { rc.ExpiresAt = jwt.NewNumericDate(now.Add(-2 * time.Minute)) }, auth.ErrTokenExpired},
+		{"future not before", func(rc *jwt.RegisteredClaims) { rc.NotBefore = jwt.NewNumericDate(now.Add(time.Hour)) }, auth.ErrTokenNotValidYet},
+		{"future issued at", func(rc *jwt.RegisteredClaims) { rc.IssuedAt = jwt.NewNumericDate(now.Add(time.Hour)) }, auth.ErrTokenUsedBeforeIssued},
+		{"old issued at", func(rc *jwt.RegisteredClaims) { rc.IssuedAt = jwt.NewNumericDate(now.Add(-48 * time.Hour)) }, auth.ErrTokenTooOld},
+		{"missing issued at", func(rc *jwt.RegisteredClaims) { rc.IssuedAt = nil }, auth.ErrMissingClaim},
+		{"other issuer", func(rc *jwt.RegisteredClaims) { rc.Issuer = "someone else" }, auth.ErrInvalidIssuer},
+		{"other audience", func(rc *jwt.RegisteredClaims) { rc.Audience = jwt.ClaimStrings{"other"} }, auth.ErrInvalidAudience},
+		{"missing subject", func(rc *jwt.RegisteredClaims) { rc.Subject = "" }, auth.ErrMissingClaim},
+		{"missing expiry", func(rc *jwt.RegisteredClaims) { rc.ExpiresAt = nil }, auth.ErrMissingClaim},
+	}
+
+	for testID, tst := range tt {
+		t.Logf("\tTest %d:\tWhen handling a token with %s.", testID, tst.name)
+		{
+			rc := valid()
+			tst.modify(&rc)
+
+			token, err := a.GenerateToken(auth.Claims{RegisteredClaims: rc, Roles: []string{auth.RoleUser}})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a JWT: %v", failed, testID, err)
+			}
+
+			_, err = a.ValidateToken(context.Background(), token)
+			switch {
+			case tst.err == nil && err != nil:
+				t.Fatalf("\t%s\tTest %d:\tShould accept the token: %v", failed, testID, err)
+			case tst.err != nil && !errors.Is(err, tst.err):
+				t.Fatalf("\t%s\tTest %d:\tShould reject the token with %q: %v", failed, testID, tst.err, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould validate the claims.", success, testID)
+		}
+	}
+
+	testID := len(tt)
+	t.Logf("\tTest %d:\tWhen handling a tampered token.", testID)
+	{
+		token, err := a.GenerateToken(auth.Claims{RegisteredClaims: valid()})
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to generate a JWT: %v", failed, testID, err)
+		}
+
+		if _, err := a.ValidateToken(context.Background(), token[:len(token)-4] + "AAAA"); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Fatalf("\t%s\tTest %d:\tShould reject the token as invalid: %v", failed, testID, err)
+		}
+		t.Logf("\t%s\tTest %d:\tShould reject the token as invalid.", success, testID)
+	}
+}
+
+func Test_IssuerKeys(t *testing.T) {
+	t.Log("Given the need to accept tokens from an identity provider.")
+
+	const keyID = "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"
+
+	localKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create a private key: %v.", failed, err)
+	}
+	remoteKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create a private key: %v.", failed, err)
+	}
+
+	a, err := auth.New(keyID, &keyStore{pk: localKey},
+		auth.WithIssuer("service project"),
+		auth.WithIssuerKeys("identity provider", &keyStore{pk: remoteKey}),
+	)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create authenticator: %v", failed, err)
+	}
+
+	// The identity provider signs its tokens with the remote key.
+	idp, err := auth.New(keyID, &keyStore{pk: remoteKey})
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create the identity provider: %v", failed, err)
+	}
+
+	tt := []struct {
+		name   string
+		signer *auth.Auth
+		issuer string
+		err    error
+	}{
+		{"our key and our issuer", a, "service project", nil},
+		{"the remote key and the remote issuer", idp, "identity provider", nil},
+		{"the remote key and our issuer", idp, "service project", auth.ErrInvalidToken},
+		{"our key and the remote issuer", a, "identity provider", auth.ErrInvalidToken},
+	}
+
+	for testID, tst := range tt {
+		t.Logf("\tTest %d:\tWhen handling a token signed with %s.", testID, tst.name)
+		{
+			claims := auth.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Issuer:    tst.issuer,
+					Subject:   "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1",
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+				},
+			}
+			token, err := tst.signer.GenerateToken(claims)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a JWT: %v", failed, testID, err)
+			}
+
+			_, err = a.ValidateToken(context.Background(), token)
+			switch {
+			case tst.err == nil && err != nil:
+				t.Fatalf("\t%s\tTest %d:\tShould accept the token: %v", failed, testID, err)
+			case tst.err != nil && !errors.Is(err, tst.err):
+				t.Fatalf("\t%s\tTest %d:\tShould reject the token with %q: %v", failed, testID, tst.err, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only verify the token with the keys of its issuer.", success, testID)
+		}
+	}
+}
+
+func Test_Revocation(t *testing.T) {
+	t.Log("Given the need to revoke tokens before they expire.")
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create a private key: %v.", failed, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "revoked.json")
+	store, err := revokedb.NewStore(path)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create the revocation store: %v", failed, err)
+	}
+
+	a, err := auth.New("54bb2165-71e1-41a6-af3e-7da4a0e1e2c1", &keyStore{pk: privateKey}, auth.WithRevocationStore(store))
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create authenticator: %v", failed, err)
+	}
+
+	ctx := context.Background()
+	claims := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen revoking a token.", testID)
+		{
+			token, err := a.GenerateToken(claims)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a JWT: %v", failed, testID, err)
+			}
+
+			parsed, err := a.ValidateToken(ctx, token)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould accept the token before it is revoked: %v", failed, testID, err)
+			}
+			if parsed.ID == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould set a token id.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould set a token id.", success, testID)
+
+			if err := a.RevokeToken(ctx, token); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke the token: %v", failed, testID, err)
+			}
+
+			if _, err := a.ValidateToken(ctx, token); !errors.Is(err, auth.ErrTokenRevoked) {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the revoked token: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the revoked token.", success, testID)
+
+			other, err := a.GenerateToken(claims)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a JWT: %v", failed, testID, err)
+			}
+			if _, err := a.ValidateToken(ctx, other); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould accept other tokens: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept other tokens.", success, testID)
+
+			reopened, err := revokedb.NewStore(path)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to reopen the revocation store: %v", failed, testID, err)
+			}
+			if revoked, err := reopened.IsRevoked(ctx, parsed.ID); err != nil || !revoked {
+				t.Fatalf("\t%s\tTest %d:\tShould persist the revocation: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould persist the revocation.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen pruning the revocation store.", testID)
+		{
+			if err := store.Revoke(ctx, "expired", time.Now().Add(-time.Minute)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke a token id: %v", failed, testID, err)
+			}
+			if revoked, _ := store.IsRevoked(ctx, "expired"); revoked {
+				t.Fatalf("\t%s\tTest %d:\tShould drop entries past their expiry.", failed, testID)
+			}
+
+			n, err := store.Prune(ctx, time.Now().Add(2*time.Hour))
+			if err != nil || n != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould prune the remaining entry : got %d, %v", failed, testID, n, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould drop entries past their expiry.", success, testID)
+		}
+	}
+}
+
+func Test_Refresh(t *testing.T) {
+	t.Log("Given the need to refresh short lived access tokens.")
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create a private key: %v.", failed, err)
+	}
+
+	store, err := refreshdb.NewStore(filepath.Join(t.TempDir(), "refresh.json"))
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create the refresh store: %v", failed, err)
+	}
+
+	a, err := auth.New("54bb2165-71e1-41a6-af3e-7da4a0e1e2c1", &keyStore{pk: privateKey}, auth.WithRefreshTokens(store, time.Hour))
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create authenticator: %v", failed, err)
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	claims := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1",
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		Roles: []string{auth.RoleUser},
+	}
+
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen refreshing a token pair.", testID)
+		{
+			first, err := a.GenerateTokenPair(ctx, claims)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a token pair: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to generate a token pair.", success, testID)
+
+			second, err := a.RefreshTokenPair(ctx, first.RefreshToken)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to refresh the token pair: %v", failed, testID, err)
+			}
+			if second.RefreshToken == first.RefreshToken {
+				t.Fatalf("\t%s\tTest %d:\tShould rotate the refresh token.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould rotate the refresh token.", success, testID)
+
+			parsed, err := a.ValidateToken(ctx, second.AccessToken)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould accept the new access token: %v", failed, testID, err)
+			}
+			if parsed.Subject != claims.Subject || !parsed.Authorized(auth.RoleUser) {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the claims : got %+v", failed, testID, parsed)
+			}
+			if lifetime := parsed.ExpiresAt.Sub(parsed.IssuedAt.Time); lifetime != 5*time.Minute {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the access token lifetime : got %v", failed, testID, lifetime)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the claims and lifetime.", success, testID)
+
+			if _, err := a.RefreshTokenPair(ctx, first.RefreshToken); !errors.Is(err, auth.ErrRefreshTokenReused) {
+				t.Fatalf("\t%s\tTest %d:\tShould detect the reuse of a refresh token: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould detect the reuse of a refresh token.", success, testID)
+
+			if _, err := a.RefreshTokenPair(ctx, second.RefreshToken); !errors.Is(err, auth.ErrRefreshTokenInvalid) {
+				t.Fatalf("\t%s\tTest %d:\tShould revoke the whole family after reuse: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould revoke the whole family after reuse.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen using an unknown refresh token.", testID)
+		{
+			if _, err := a.RefreshTokenPair(ctx, "unknown"); !errors.Is(err, auth.ErrRefreshTokenInvalid) {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the refresh token: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the refresh token.", success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen the user changes after the token pair was issued.", testID)
+		{
+			users := userLookup{claims.Subject: {auth.RoleUser}}
+			b, err := auth.New("54bb2165-71e1-41a6-af3e-7da4a0e1e2c1", &keyStore{pk: privateKey}, auth.WithRefreshTokens(store, time.Hour), auth.WithUserLookup(users))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create authenticator: %v", failed, testID, err)
+			}
+
+			first, err := b.GenerateTokenPair(ctx, claims)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a token pair: %v", failed, testID, err)
+			}
+			second, err := b.RefreshTokenPair(ctx, first.RefreshToken)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould refresh while the user is unchanged: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refresh while the user is unchanged.", success, testID)
+
+			users[claims.Subject] = []string{auth.RoleAdmin}
+			if _, err := b.RefreshTokenPair(ctx, second.RefreshToken); !errors.Is(err, auth.ErrRefreshTokenInvalid) {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the refresh token once the roles change: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the refresh token once the roles change.", success, testID)
+
+			users[claims.Subject] = []string{auth.RoleUser}
+			if _, err := b.RefreshTokenPair(ctx, second.RefreshToken); !errors.Is(err, auth.ErrRefreshTokenInvalid) {
+				t.Fatalf("\t%s\tTest %d:\tShould revoke the family once the roles change: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould revoke the family once the roles change.", success, testID)
+
+			third, err := b.GenerateTokenPair(ctx, claims)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a token pair: %v", failed, testID, err)
+			}
+			delete(users, claims.Subject)
+			if _, err := b.RefreshTokenPair(ctx, third.RefreshToken); !errors.Is(err, auth.ErrRefreshTokenInvalid) {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the refresh token once the user is deleted: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the refresh token once the user is deleted.", success, testID)
+		}
+	}
+}
+
+// userLookup holds the current roles of each user.
+type userLookup map[string][]string
+
+func (ul userLookup) UserRoles(ctx context.Context, userID string) ([]string, error) {
+	roles, ok := ul[userID]
+	if !ok {
+		return nil, auth.ErrUserNotFound
+	}
+	return roles, nil
+}
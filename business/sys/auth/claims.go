@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
+
 	"github.com/golang-jwt/jwt/v4"
 )
 
@@ -51,17 +53,171 @@ func GetClaims(ctx context.Context) Claims {
 	return v
 }
 
-func (a *Auth) ValidateToken(tokenStr string) (Claims, error) {
+// ValidateToken verifies the signature of the token and validates its claims
+// against the options provided to New. The returned error wraps one of the
+// Err values describing why the token was rejected.
+func (a *Auth) ValidateToken(ctx context.Context, tokenStr string) (Claims, error) {
+	claims, err := a.parseToken(tokenStr)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	if err := a.validateClaims(claims, time.Now()); err != nil {
+		return Claims{}, err
+	}
+
+	if a.opts.revocations != nil && claims.ID != "" {
+		revoked, err := a.opts.revocations.IsRevoked(ctx, claims.ID)
+		if err != nil {
+			return Claims{}, fmt.Errorf("checking revocation: %w", err)
+		}
+		if revoked {
+			return Claims{}, fmt.Errorf("%w: %s", ErrTokenRevoked, claims.ID)
+		}
+	}
+
+	return claims, nil
+}
+
+// ValidateAPIKey authenticates the API key and returns the claims it
+// carries. The returned error wraps ErrInvalidAPIKey when the key is
+// rejected.
+func (a *Auth) ValidateAPIKey(ctx context.Context, key string) (Claims, error) {
+	if a.opts.apiKeys == nil {
+		return Claims{}, fmt.Errorf("%w: api keys are not accepted", ErrInvalidAPIKey)
+	}
+
+	claims, err := a.opts.apiKeys.AuthenticateAPIKey(ctx, key)
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %v", ErrInvalidAPIKey, err)
+	}
+
+	return claims, nil
+}
+
+// RevokeToken records the id of the token in the revocation store so it is
+// rejected from now on. The signature of the token must be valid, but the
+// token may fail the other rules so a token can be revoked regardless.
+// Revoking a token that already expired does nothing.
+func (a *Auth) RevokeToken(ctx context.Context, tokenStr string) error {
+	if a.opts.revocations == nil {
+		return errors.New("no revocation store configured")
+	}
+
+	claims, err := a.parseToken(tokenStr)
+	if err != nil {
+		return err
+	}
+
+	if claims.ID == "" {
+		return fmt.Errorf("%w: %s", ErrMissingClaim, ClaimID)
+	}
+
+	var expiresAt time.Time
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Add(a.opts.leeway)
+		if time.Now().After(expiresAt) {
+			return nil
+		}
+	}
+
+	if err := a.opts.revocations.Revoke(ctx, claims.ID, expiresAt); err != nil {
+		return fmt.Errorf("revoking token: %w", err)
+	}
+
+	return nil
+}
+
+// parseToken verifies the signature of the token and returns its claims.
+func (a *Auth) parseToken(tokenStr string) (Claims, error) {
 	var claims Claims
 	token, err := a.parser.ParseWithClaims(tokenStr, &claims, a.keyFunc)
 	if err != nil {
-		return Claims{}, fmt.Errorf("parsing token: %w", err)
+		return Claims{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
 	}
 
 	if !token.Valid {
-		return Claims{}, errors.New("invalid token")
+		return Claims{}, ErrInvalidToken
 	}
+
 	return claims, nil
 }
 
+// validateClaims checks the claims against the options at the specified
+// time.
+func (a *Auth) validateClaims(claims Claims, now time.Time) error {
+	o := a.opts
+
+	for _, claim := range o.requiredClaims {
+		var missing bool
+		switch claim {
+		case ClaimSubject:
+			missing = claims.Subject == ""
+		case ClaimExpiresAt:
+			missing = claims.ExpiresAt == nil
+		case ClaimIssuedAt:
+			missing = claims.IssuedAt == nil
+		case ClaimNotBefore:
+			missing = claims.NotBefore == nil
+		case ClaimIssuer:
+			missing = claims.Issuer == ""
+		case ClaimAudience:
+			missing = len(claims.Audience) == 0
+		case ClaimID:
+			missing = claims.ID == ""
+		}
+		if missing {
+			return fmt.Errorf("%w: %s", ErrMissingClaim, claim)
+		}
+	}
+
+	if claims.ExpiresAt != nil && now.After(claims.ExpiresAt.Add(o.leeway)) {
+		return fmt.Errorf("%w: expired at %s", ErrTokenExpired, claims.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+
+	if claims.NotBefore != nil && now.Add(o.leeway).Before(claims.NotBefore.Time) {
+		return fmt.Errorf("%w: valid from %s", ErrTokenNotValidYet, claims.NotBefore.UTC().Format(time.RFC3339))
+	}
+
+	if claims.IssuedAt != nil && now.Add(o.leeway).Before(claims.IssuedAt.Time) {
+		return fmt.Errorf("%w: issued at %s", ErrTokenUsedBeforeIssued, claims.IssuedAt.UTC().Format(time.RFC3339))
+	}
 
+	if o.maxAge > 0 {
+		if claims.IssuedAt == nil {
+			return fmt.Errorf("%w: %s", ErrMissingClaim, ClaimIssuedAt)
+		}
+		if now.Sub(claims.IssuedAt.Time) > o.maxAge+o.leeway {
+			return fmt.Errorf("%w: issued at %s", ErrTokenTooOld, claims.IssuedAt.UTC().Format(time.RFC3339))
+		}
+	}
+
+	if len(o.issuers) > 0 && !contains(o.issuers, claims.Issuer) {
+		return fmt.Errorf("%w: %q", ErrInvalidIssuer, claims.Issuer)
+	}
+
+	if len(o.audiences) > 0 {
+		var accepted bool
+		for _, aud := range claims.Audience {
+			if contains(o.audiences, aud) {
+				accepted = true
+				break
+			}
+		}
+		if !accepted {
+			return fmt.Errorf("%w: %v", ErrInvalidAudience, []string(claims.Audience))
+		}
+	}
+
+	return nil
+}
+
+// contains reports if the value is in the list.
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
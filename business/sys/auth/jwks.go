@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// KIDLister is implemented by a KeyLookup that can list the ids of the keys
+// that are currently valid for verifying tokens.
+type KIDLister interface {
+	KIDs() []string
+}
+
+// JWK is a JSON Web Key as defined in RFC 7517 holding an RSA, EC or OKP
+// public key.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg,omitempty"`
+	Use       string `json:"use,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKSet is a set of JSON Web Keys.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK constructs the JWK for a public key used to verify signatures.
+func NewJWK(kid string, algorithm string, publicKey crypto.PublicKey) (JWK, error) {
+	jwk := JWK{
+		KeyID:     kid,
+		Algorithm: algorithm,
+		Use:       "sig",
+	}
+
+	switch pk := publicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = encode(pk.N.Bytes())
+		jwk.E = encode(big.NewInt(int64(pk.E)).Bytes())
+
+	case *ecdsa.PublicKey:
+		size := (pk.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = pk.Curve.Params().Name
+		jwk.X = encode(pk.X.FillBytes(make([]byte, size)))
+		jwk.Y = encode(pk.Y.FillBytes(make([]byte, size)))
+
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = encode(pk)
+
+	default:
+		return JWK{}, fmt.Errorf("unsupported key type %T", publicKey)
+	}
+
+	return jwk, nil
+}
+
+// PublicKey returns the public key held by the JWK.
+func (jwk JWK) PublicKey() (crypto.PublicKey, error) {
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("decoding modulus: %w", err)
+		}
+
+		e, err := decode(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("decoding exponent: %w", err)
+		}
+
+		exp := new(big.Int).SetBytes(e)
+		if len(n) == 0 || !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid rsa public key")
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Curve)
+		}
+
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, fmt.Errorf("decoding x: %w", err)
+		}
+
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return nil, fmt.Errorf("decoding y: %w", err)
+		}
+
+		pk := ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pk.X, pk.Y) {
+			return nil, errors.New("invalid ec public key")
+		}
+
+		return &pk, nil
+
+	case "OKP":
+		if jwk.Curve != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Curve)
+		}
+
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, fmt.Errorf("decoding x: %w", err)
+		}
+
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 public key")
+		}
+
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", jwk.KeyType)
+}
+
+// JWKS returns the public keys that verify tokens as a JWK set so other
+// services can validate the tokens this service issues. The KeyLookup must
+// implement KIDLister.
+func (a *Auth) JWKS() (JWKSet, error) {
+	kl, ok := a.keyLookup.(KIDLister)
+	if !ok {
+		return JWKSet{}, errors.New("key lookup can't list its keys")
+	}
+
+	kids := kl.KIDs()
+	sort.Strings(kids)
+
+	set := JWKSet{
+		Keys: make([]JWK, 0, len(kids)),
+	}
+
+	for _, kid := range kids {
+		publicKey, err := a.keyLookup.PublicKeyPEM(kid)
+		if err != nil {
+
+			// The key retired after it was listed.
+			continue
+		}
+
+		alg, err := algorithm(a.keyLookup, kid)
+		if err != nil {
+			continue
+		}
+		if alg == "" {
+			if alg, err = DefaultAlgorithm(publicKey); err != nil {
+				return JWKSet{}, fmt.Errorf("key %q: %w", kid, err)
+			}
+		}
+
+		jwk, err := NewJWK(kid, alg, publicKey)
+		if err != nil {
+			return JWKSet{}, fmt.Errorf("key %q: %w", kid, err)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set, nil
+}
+
+// encode encodes the bytes as unpadded base64url.
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decode decodes unpadded base64url.
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -0,0 +1,68 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/internal/keystore"
+)
+
+func Test_JWKS(t *testing.T) {
+	t.Log("Given the need to publish the keys that verify tokens.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the key store holds a key.", testID)
+		{
+			const keyID = "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"
+			privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a private key: %v.", failed, testID, err)
+			}
+
+			ks, err := keystore.NewMap(map[string]crypto.Signer{keyID: privateKey})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create the key store: %v.", failed, testID, err)
+			}
+			if err := ks.Activate(keyID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to activate the key: %v.", failed, testID, err)
+			}
+
+			a, err := auth.New(keyID, ks)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create authenticator: %v", failed, testID, err)
+			}
+
+			set, err := a.JWKS()
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to build the key set: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to build the key set.", success, testID)
+
+			if len(set.Keys) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould have a single key: got %d", failed, testID, len(set.Keys))
+			}
+			jwk := set.Keys[0]
+
+			if jwk.KeyID != keyID || jwk.Algorithm != "RS256" || jwk.Use != "sig" || jwk.KeyType != "RSA" {
+				t.Fatalf("\t%s\tTest %d:\tShould describe the key: %+v", failed, testID, jwk)
+			}
+			t.Logf("\t%s\tTest %d:\tShould describe the key.", success, testID)
+
+			n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+			if err != nil || new(big.Int).SetBytes(n).Cmp(privateKey.N) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould encode the modulus: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould encode the modulus.", success, testID)
+
+			if jwk.E != "AQAB" {
+				t.Fatalf("\t%s\tTest %d:\tShould encode the exponent: got %q", failed, testID, jwk.E)
+			}
+			t.Logf("\t%s\tTest %d:\tShould encode the exponent.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Set of errors returned by ValidateToken describing why a token was
+// rejected. The returned error wraps one of these and adds detail.
+var (
+	ErrInvalidToken          = errors.New("token is malformed or has an invalid signature")
+	ErrTokenExpired          = errors.New("token has expired")
+	ErrTokenNotValidYet      = errors.New("token is not valid yet")
+	ErrTokenUsedBeforeIssued = errors.New("token used before it was issued")
+	ErrTokenTooOld           = errors.New("token exceeds the maximum age")
+	ErrInvalidIssuer         = errors.New("token issuer is not accepted")
+	ErrInvalidAudience       = errors.New("token audience is not accepted")
+	ErrMissingClaim          = errors.New("token is missing a required claim")
+	ErrTokenRevoked          = errors.New("token has been revoked")
+	ErrInvalidAPIKey         = errors.New("api key is invalid, revoked or expired")
+)
+
+// Set of registered claims that can be required.
+const (
+	ClaimSubject   = "sub"
+	ClaimExpiresAt = "exp"
+	ClaimIssuedAt  = "iat"
+	ClaimNotBefore = "nbf"
+	ClaimIssuer    = "iss"
+	ClaimAudience  = "aud"
+	ClaimID        = "jti"
+)
+
+// options holds the rules a token's claims are validated against.
+type options struct {
+	issuers        []string
+	issuerKeys     map[string]KeyLookup
+	audiences      []string
+	maxAge         time.Duration
+	leeway         time.Duration
+	requiredClaims []string
+	revocations    RevocationStore
+	refreshes      RefreshStore
+	refreshTTL     time.Duration
+	users          UserLookup
+	policy         *Policy
+	apiKeys        APIKeyAuthenticator
+}
+
+// Option configures how New validates the claims of a token.
+type Option func(*options)
+
+// WithIssuer only accepts tokens issued by one of the issuers.
+func WithIssuer(issuers ...string) Option {
+	return func(o *options) {
+		o.issuers = append(o.issuers, issuers...)
+	}
+}
+
+// WithIssuerKeys accepts tokens issued by the issuer and verifies them only
+// with the keys of the key lookup, such as the keys published by an identity
+// provider. Tokens of other issuers are verified with the key lookup provided
+// to New, so neither can sign tokens in the name of the other.
+func WithIssuerKeys(issuer string, keyLookup KeyLookup) Option {
+	return func(o *options) {
+		if o.issuerKeys == nil {
+			o.issuerKeys = make(map[string]KeyLookup)
+		}
+		o.issuerKeys[issuer] = keyLookup
+		o.issuers = append(o.issuers, issuer)
+	}
+}
+
+// WithAudiences only accepts tokens intended for at least one of the
+// audiences.
+func WithAudiences(audiences ...string) Option {
+	return func(o *options) {
+		o.audiences = append(o.audiences, audiences...)
+	}
+}
+
+// WithMaxAge rejects tokens issued longer ago than the duration, regardless
+// of their expiry. The token must carry the iat claim.
+func WithMaxAge(d time.Duration) Option {
+	return func(o *options) {
+		o.maxAge = d
+	}
+}
+
+// WithLeeway allows for clock skew between the issuer and this service when
+// checking the time based claims.
+func WithLeeway(d time.Duration) Option {
+	return func(o *options) {
+		o.leeway = d
+	}
+}
+
+// WithRequiredClaims rejects tokens that don't carry each of the registered
+// claims, such as ClaimSubject and ClaimExpiresAt.
+func WithRequiredClaims(claims ...string) Option {
+	return func(o *options) {
+		o.requiredClaims = append(o.requiredClaims, claims...)
+	}
+}
+
+// RevocationStore declares the behavior for recording the ids (jti) of
+// tokens that were revoked before they expired.
+type RevocationStore interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// WithRevocationStore rejects tokens whose id has been revoked in the store.
+// Tokens without an id can't be revoked and are not checked.
+func WithRevocationStore(rs RevocationStore) Option {
+	return func(o *options) {
+		o.revocations = rs
+	}
+}
+
+// APIKeyAuthenticator declares the behavior for authenticating a client with
+// an API key instead of a token. The claims it returns are treated the same
+// as the claims of a token.
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (Claims, error)
+}
+
+// WithAPIKeys accepts API keys checked by the authenticator.
+func WithAPIKeys(ak APIKeyAuthenticator) Option {
+	return func(o *options) {
+		o.apiKeys = ak
+	}
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Set of permissions checked by the routes. A permission names a resource
+// and an action on it. The apikeys:read and apikeys:write permissions apply
+// to the keys of the user, apikeys:admin to the keys of every user.
+const (
+	PermUsersRead      = "users:read"
+	PermUsersWrite     = "users:write"
+	PermTokensRevoke   = "tokens:revoke"
+	PermAPIKeysRead    = "apikeys:read"
+	PermAPIKeysWrite   = "apikeys:write"
+	PermAPIKeysAdmin   = "apikeys:admin"
+	PermProductsRead   = "products:read"
+	PermProductsWrite  = "products:write"
+	PermProductsDelete = "products:delete"
+)
+
+// Policy maps roles to the permissions they grant. A role may be granted
+// "*" for every permission or "resource:*" for every action on a resource.
+type Policy struct {
+	roles map[string]map[string]struct{}
+}
+
+// NewPolicy constructs a Policy from a table of roles and their permissions.
+func NewPolicy(roles map[string][]string) (*Policy, error) {
+	p := Policy{
+		roles: make(map[string]map[string]struct{}, len(roles)),
+	}
+
+	for role, perms := range roles {
+		if role == "" {
+			return nil, errors.New("empty role name")
+		}
+
+		set := make(map[string]struct{}, len(perms))
+		for _, perm := range perms {
+			if perm != "*" {
+				resource, action, ok := strings.Cut(perm, ":")
+				if !ok || resource == "" || action == "" {
+					return nil, fmt.Errorf("role %q: permission %q must be in the form resource:action", role, perm)
+				}
+			}
+			set[perm] = struct{}{}
+		}
+		p.roles[role] = set
+	}
+
+	return &p, nil
+}
+
+// DefaultPolicy returns the policy used when none is configured. Admins are
+// granted every permission and users none.
+func DefaultPolicy() *Policy {
+	p, _ := NewPolicy(map[string][]string{
+		RoleAdmin: {"*"},
+		RoleUser:  {},
+	})
+	return p
+}
+
+// policyDocument is the layout of a policy file.
+type policyDocument struct {
+	Roles map[string][]string `json:"roles"`
+}
+
+// LoadPolicy reads a policy document in JSON.
+// Example: {"roles": {"ADMIN": ["*"], "USER": ["products:read"]}}
+func LoadPolicy(r io.Reader) (*Policy, error) {
+	var doc policyDocument
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decoding policy: %w", err)
+	}
+
+	return NewPolicy(doc.Roles)
+}
+
+// LoadPolicyFile reads a policy document from the named file.
+func LoadPolicyFile(path string) (*Policy, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening policy file: %w", err)
+	}
+	defer file.Close()
+
+	return LoadPolicy(file)
+}
+
+// HasRole reports if the policy defines the role.
+func (p *Policy) HasRole(role string) bool {
+	_, exists := p.roles[role]
+	return exists
+}
+
+// Granted reports if any of the roles grants the permission.
+func (p *Policy) Granted(roles []string, perm string) bool {
+	resource, _, _ := strings.Cut(perm, ":")
+
+	for _, role := range roles {
+		set, exists := p.roles[role]
+		if !exists {
+			continue
+		}
+
+		if _, ok := set[perm]; ok {
+			return true
+		}
+		if _, ok := set["*"]; ok {
+			return true
+		}
+		if _, ok := set[resource+":*"]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WithPolicy sets the policy HasPermissions checks the roles of the claims
+// against. Without it DefaultPolicy is used.
+func WithPolicy(p *Policy) Option {
+	return func(o *options) {
+		o.policy = p
+	}
+}
+
+// HasPermissions reports if the roles of the claims grant every one of the
+// permissions.
+func (a *Auth) HasPermissions(claims Claims, perms ...string) bool {
+	for _, perm := range perms {
+		if !a.opts.policy.Granted(claims.Roles, perm) {
+			return false
+		}
+	}
+	return true
+}
+
+// AuthorizeOwner allows the action when the claims belong to the owner of
+// the resource, or when the roles of the claims grant every one of the
+// permissions. Without permissions only the owner is allowed. The returned
+// error wraps ErrForbidden.
+func (a *Auth) AuthorizeOwner(claims Claims, ownerID string, perms ...string) error {
+	if claims.Subject != "" && claims.Subject == ownerID {
+		return nil
+	}
+
+	if len(perms) > 0 && a.HasPermissions(claims, perms...) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: subject %q does not own %q", ErrForbidden, claims.Subject, ownerID)
+}
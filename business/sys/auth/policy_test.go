@@ -0,0 +1,112 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ardanlabs/service/business/sys/auth"
+)
+
+func Test_Policy(t *testing.T) {
+	t.Log("Given the need to map roles to permissions.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen loading a policy document.", testID)
+		{
+			doc := `{"roles": {"ADMIN": ["*"], "EDITOR": ["users:read", "products:*"], "USER": []}}`
+
+			p, err := auth.LoadPolicy(strings.NewReader(doc))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to load the policy: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to load the policy.", success, testID)
+
+			tt := []struct {
+				roles   []string
+				perm    string
+				granted bool
+			}{
+				{[]string{"ADMIN"}, auth.PermUsersWrite, true},
+				{[]string{"EDITOR"}, auth.PermUsersRead, true},
+				{[]string{"EDITOR"}, auth.PermUsersWrite, false},
+				{[]string{"EDITOR"}, auth.PermProductsDelete, true},
+				{[]string{"USER"}, auth.PermUsersRead, false},
+				{[]string{"USER", "EDITOR"}, auth.PermUsersRead, true},
+				{[]string{"UNKNOWN"}, auth.PermUsersRead, false},
+			}
+
+			for _, tst := range tt {
+				if got := p.Granted(tst.roles, tst.perm); got != tst.granted {
+					t.Fatalf("\t%s\tTest %d:\tShould grant %v %s = %v : got %v", failed, testID, tst.roles, tst.perm, tst.granted, got)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould grant the permissions of each role.", success, testID)
+
+			if !p.HasRole("EDITOR") || !p.HasRole("USER") || p.HasRole("UNKNOWN") {
+				t.Fatalf("\t%s\tTest %d:\tShould only define the roles of the document.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only define the roles of the document.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen loading an invalid policy document.", testID)
+		{
+			if _, err := auth.LoadPolicy(strings.NewReader(`{"roles": {"ADMIN": ["users"]}}`)); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a permission without an action.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject a permission without an action.", success, testID)
+		}
+	}
+}
+
+func Test_AuthorizeOwner(t *testing.T) {
+	t.Log("Given the need to let users act only on the resources they own.")
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create a private key: %v.", failed, err)
+	}
+
+	a, err := auth.New("54bb2165-71e1-41a6-af3e-7da4a0e1e2c1", &keyStore{pk: privateKey})
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create authenticator: %v", failed, err)
+	}
+
+	const owner = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+	claims := func(subject string, roles ...string) auth.Claims {
+		var c auth.Claims
+		c.Subject = subject
+		c.Roles = roles
+		return c
+	}
+
+	tt := []struct {
+		name    string
+		claims  auth.Claims
+		perms   []string
+		allowed bool
+	}{
+		{"the owner", claims(owner, auth.RoleUser), []string{auth.PermUsersWrite}, true},
+		{"another user", claims("other", auth.RoleUser), []string{auth.PermUsersWrite}, false},
+		{"an admin", claims("other", auth.RoleAdmin), []string{auth.PermUsersWrite}, true},
+		{"an admin without an override", claims("other", auth.RoleAdmin), nil, false},
+		{"no subject", claims("", auth.RoleUser), nil, false},
+	}
+
+	for testID, tst := range tt {
+		t.Logf("\tTest %d:\tWhen acting as %s.", testID, tst.name)
+		{
+			err := a.AuthorizeOwner(tst.claims, owner, tst.perms...)
+			switch {
+			case tst.allowed && err != nil:
+				t.Fatalf("\t%s\tTest %d:\tShould be allowed: %v", failed, testID, err)
+			case !tst.allowed && !errors.Is(err, auth.ErrForbidden):
+				t.Fatalf("\t%s\tTest %d:\tShould be forbidden: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould authorize the action.", success, testID)
+		}
+	}
+}
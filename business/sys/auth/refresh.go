@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+// Set of errors returned when refreshing a token pair.
+var (
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenInvalid  = errors.New("refresh token is invalid or has expired")
+	ErrRefreshTokenReused   = errors.New("refresh token has already been used")
+	ErrUserNotFound         = errors.New("user not found")
+)
+
+// RefreshToken is the server side record of an opaque refresh token. Only
+// the hash of the token is kept. Each refresh replaces the token with a new
+// one in the same family, so the reuse of an old token can be detected.
+type RefreshToken struct {
+	Hash      string        `json:"hash"`
+	FamilyID  string        `json:"family_id"`
+	Claims    Claims        `json:"claims"`
+	Lifetime  time.Duration `json:"lifetime"`
+	ExpiresAt time.Time     `json:"expires_at"`
+	UsedAt    time.Time     `json:"used_at,omitempty"`
+	Revoked   bool          `json:"revoked,omitempty"`
+}
+
+// RefreshStore declares the behavior for keeping the refresh tokens that
+// were issued.
+type RefreshStore interface {
+	Create(ctx context.Context, rt RefreshToken) error
+
+	// Use returns the token as it was before the call and marks it as used
+	// at the specified time if it wasn't already. It must be atomic so a
+	// token can't be used twice concurrently. ErrRefreshTokenNotFound is
+	// returned for an unknown hash.
+	Use(ctx context.Context, hash string, now time.Time) (RefreshToken, error)
+
+	RevokeFamily(ctx context.Context, familyID string) error
+}
+
+// WithRefreshTokens enables token pairs. Refresh tokens are kept in the store
+// and can be used once within the ttl to get a new pair.
+func WithRefreshTokens(rs RefreshStore, ttl time.Duration) Option {
+	return func(o *options) {
+		o.refreshes = rs
+		o.refreshTTL = ttl
+	}
+}
+
+// UserLookup declares the behavior for looking up the current roles of the
+// user a refresh token was issued to. ErrUserNotFound is returned for a user
+// that no longer exists.
+type UserLookup interface {
+	UserRoles(ctx context.Context, userID string) ([]string, error)
+}
+
+// WithUserLookup checks the user still exists with the same roles each time
+// a refresh token is used. Otherwise the family of the refresh token is
+// revoked and the user has to authenticate again.
+func WithUserLookup(ul UserLookup) Option {
+	return func(o *options) {
+		o.users = ul
+	}
+}
+
+// TokenPair is a short lived access token and the refresh token used to
+// get the next pair once it expires.
+type TokenPair struct {
+	AccessToken  string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// GenerateTokenPair generates an access token for the claims along with a
+// refresh token that starts a new family. The claims must carry an expiry,
+// the lifetime of the access token is kept for each refresh.
+func (a *Auth) GenerateTokenPair(ctx context.Context, claims Claims) (TokenPair, error) {
+	if a.opts.refreshes == nil {
+		return TokenPair{}, errors.New("no refresh store configured")
+	}
+
+	if claims.ExpiresAt == nil {
+		return TokenPair{}, fmt.Errorf("%w: %s", ErrMissingClaim, ClaimExpiresAt)
+	}
+
+	issuedAt := time.Now()
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+	lifetime := claims.ExpiresAt.Sub(issuedAt)
+	if lifetime <= 0 {
+		return TokenPair{}, errors.New("access token expires before it is issued")
+	}
+
+	return a.generateTokenPair(ctx, claims, uuid.New().String(), lifetime, issuedAt)
+}
+
+// RefreshTokenPair exchanges a refresh token for a new token pair. The
+// refresh token can only be used once. Using it again means it leaked, so
+// every token in its family is revoked and ErrRefreshTokenReused is
+// returned. With a UserLookup the family is also revoked once the user is
+// deleted or their roles change.
+func (a *Auth) RefreshTokenPair(ctx context.Context, refreshToken string) (TokenPair, error) {
+	if a.opts.refreshes == nil {
+		return TokenPair{}, errors.New("no refresh store configured")
+	}
+
+	now := time.Now()
+
+	rt, err := a.opts.refreshes.Use(ctx, hashRefreshToken(refreshToken), now)
+	if err != nil {
+		if errors.Is(err, ErrRefreshTokenNotFound) {
+			return TokenPair{}, ErrRefreshTokenInvalid
+		}
+		return TokenPair{}, fmt.Errorf("using refresh token: %w", err)
+	}
+
+	if rt.Revoked || !now.Before(rt.ExpiresAt) {
+		return TokenPair{}, ErrRefreshTokenInvalid
+	}
+
+	if !rt.UsedAt.IsZero() {
+		if err := a.opts.refreshes.RevokeFamily(ctx, rt.FamilyID); err != nil {
+			return TokenPair{}, fmt.Errorf("revoking token family: %w", err)
+		}
+		return TokenPair{}, ErrRefreshTokenReused
+	}
+
+	if a.opts.users != nil {
+		roles, err := a.opts.users.UserRoles(ctx, rt.Claims.Subject)
+		switch {
+		case errors.Is(err, ErrUserNotFound):
+			return TokenPair{}, a.revokeFamily(ctx, rt.FamilyID, "user no longer exists")
+
+		case err != nil:
+			return TokenPair{}, fmt.Errorf("looking up user: %w", err)
+
+		case !sameRoles(roles, rt.Claims.Roles):
+			return TokenPair{}, a.revokeFamily(ctx, rt.FamilyID, "user roles have changed")
+		}
+	}
+
+	return a.generateTokenPair(ctx, rt.Claims, rt.FamilyID, rt.Lifetime, now)
+}
+
+// revokeFamily revokes every token in the family because the claims they
+// carry are out of date, and returns why the refresh token is invalid.
+func (a *Auth) revokeFamily(ctx context.Context, familyID string, reason string) error {
+	if err := a.opts.refreshes.RevokeFamily(ctx, familyID); err != nil {
+		return fmt.Errorf("revoking token family: %w", err)
+	}
+	return fmt.Errorf("%w: %s", ErrRefreshTokenInvalid, reason)
+}
+
+// sameRoles reports if both lists hold the same roles, in any order.
+func sameRoles(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, role := range a {
+		if !contains(b, role) {
+			return false
+		}
+	}
+	for _, role := range b {
+		if !contains(a, role) {
+			return false
+		}
+	}
+	return true
+}
+
+// generateTokenPair issues a new access token from the claims and stores a
+// new refresh token in the family.
+func (a *Auth) generateTokenPair(ctx context.Context, claims Claims, familyID string, lifetime time.Duration, now time.Time) (TokenPair, error) {
+	claims.ID = ""
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(lifetime))
+	if claims.NotBefore != nil {
+		claims.NotBefore = jwt.NewNumericDate(now)
+	}
+
+	accessToken, err := a.GenerateToken(claims)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	rt := RefreshToken{
+		Hash:      hashRefreshToken(refreshToken),
+		FamilyID:  familyID,
+		Claims:    claims,
+		Lifetime:  lifetime,
+		ExpiresAt: now.Add(a.opts.refreshTTL),
+	}
+
+	if err := a.opts.refreshes.Create(ctx, rt); err != nil {
+		return TokenPair{}, fmt.Errorf("storing refresh token: %w", err)
+	}
+
+	tp := TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    claims.ExpiresAt.Time,
+	}
+
+	return tp, nil
+}
+
+// newRefreshToken returns a random opaque token.
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating refresh token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashRefreshToken returns the hash a refresh token is stored under.
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,184 @@
+// Package refreshdb keeps the refresh tokens that were issued in an
+// in-process store. Data lives in memory and, when a path is provided, is
+// persisted to a single JSON file on every write.
+package refreshdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/auth"
+)
+
+// Store manages the set of APIs for refresh token access.
+type Store struct {
+	mu     sync.Mutex
+	path   string
+	tokens map[string]auth.RefreshToken
+}
+
+// NewStore constructs the api for data access. An empty path keeps all data
+// in memory for the life of the process. Tokens that have already expired
+// are dropped when the file is loaded.
+func NewStore(path string) (*Store, error) {
+	s := Store{
+		path:   path,
+		tokens: make(map[string]auth.RefreshToken),
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	s.prune(time.Now())
+
+	return &s, nil
+}
+
+// Create adds a new refresh token to the store.
+func (s *Store) Create(ctx context.Context, rt auth.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[rt.Hash] = rt
+	s.prune(time.Now())
+
+	return s.save()
+}
+
+// Use returns the refresh token as it was before the call and marks it as
+// used at the specified time if it wasn't already.
+func (s *Store) Use(ctx context.Context, hash string, now time.Time) (auth.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rt, exists := s.tokens[hash]
+	if !exists {
+		return auth.RefreshToken{}, auth.ErrRefreshTokenNotFound
+	}
+
+	if !rt.UsedAt.IsZero() {
+		return rt, nil
+	}
+
+	used := rt
+	used.UsedAt = now
+	s.tokens[hash] = used
+
+	if err := s.save(); err != nil {
+		return auth.RefreshToken{}, err
+	}
+
+	return rt, nil
+}
+
+// RevokeFamily revokes every refresh token in the family.
+func (s *Store) RevokeFamily(ctx context.Context, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, rt := range s.tokens {
+		if rt.FamilyID == familyID {
+			rt.Revoked = true
+			s.tokens[hash] = rt
+		}
+	}
+
+	return s.save()
+}
+
+// Prune removes the refresh tokens that expired before the specified time.
+// It returns the number of tokens removed.
+func (s *Store) Prune(ctx context.Context, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.prune(now)
+	if n == 0 {
+		return 0, nil
+	}
+
+	return n, s.save()
+}
+
+// prune removes the expired tokens. The caller must hold the lock.
+func (s *Store) prune(now time.Time) int {
+	var n int
+	for hash, rt := range s.tokens {
+		if rt.ExpiresAt.Before(now) {
+			delete(s.tokens, hash)
+			n++
+		}
+	}
+	return n
+}
+
+// load reads the refresh tokens from the database file, if one exists.
+func (s *Store) load() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("reading database file: %w", err)
+	}
+
+	var tokens []auth.RefreshToken
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return fmt.Errorf("decoding database file: %w", err)
+	}
+
+	for _, rt := range tokens {
+		s.tokens[rt.Hash] = rt
+	}
+
+	return nil
+}
+
+// save writes the current set of refresh tokens to the database file. The
+// data is written to a temporary file first and renamed so a crash never
+// leaves a partially written file behind. The caller must hold the lock.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	tokens := make([]auth.RefreshToken, 0, len(s.tokens))
+	for _, rt := range s.tokens {
+		tokens = append(tokens, rt)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].Hash < tokens[j].Hash
+	})
+
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding database file: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("creating database directory: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing database file: %w", err)
+	}
+
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("replacing database file: %w", err)
+	}
+
+	return nil
+}
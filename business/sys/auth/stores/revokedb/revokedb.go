@@ -0,0 +1,158 @@
+// Package revokedb keeps the ids of revoked tokens in an in-process store.
+// Data lives in memory and, when a path is provided, is persisted to a
+// single JSON file on every write.
+package revokedb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// dbRevocation is how a revoked token is stored in the file.
+type dbRevocation struct {
+	ID        string    `json:"jti"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Store manages the set of APIs for revoked token access.
+type Store struct {
+	mu      sync.RWMutex
+	path    string
+	revoked map[string]time.Time
+}
+
+// NewStore constructs the api for data access. An empty path keeps all data
+// in memory for the life of the process. Entries that have already expired
+// are dropped when the file is loaded.
+func NewStore(path string) (*Store, error) {
+	s := Store{
+		path:    path,
+		revoked: make(map[string]time.Time),
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	s.prune(time.Now())
+
+	return &s, nil
+}
+
+// Revoke records the token id as revoked until the token expires. A zero
+// expiry keeps the entry forever.
+func (s *Store) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revoked[jti] = expiresAt
+	s.prune(time.Now())
+
+	return s.save()
+}
+
+// IsRevoked reports if the token id has been revoked.
+func (s *Store) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, exists := s.revoked[jti]
+	return exists, nil
+}
+
+// Prune removes the entries of tokens that expired before the specified
+// time, since an expired token is rejected anyway. It returns the number of
+// entries removed.
+func (s *Store) Prune(ctx context.Context, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.prune(now)
+	if n == 0 {
+		return 0, nil
+	}
+
+	return n, s.save()
+}
+
+// prune removes the expired entries. The caller must hold the lock.
+func (s *Store) prune(now time.Time) int {
+	var n int
+	for jti, expiresAt := range s.revoked {
+		if !expiresAt.IsZero() && expiresAt.Before(now) {
+			delete(s.revoked, jti)
+			n++
+		}
+	}
+	return n
+}
+
+// load reads the revoked tokens from the database file, if one exists.
+func (s *Store) load() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("reading database file: %w", err)
+	}
+
+	var revoked []dbRevocation
+	if err := json.Unmarshal(data, &revoked); err != nil {
+		return fmt.Errorf("decoding database file: %w", err)
+	}
+
+	for _, dbRev := range revoked {
+		s.revoked[dbRev.ID] = dbRev.ExpiresAt
+	}
+
+	return nil
+}
+
+// save writes the current set of revoked tokens to the database file. The
+// data is written to a temporary file first and renamed so a crash never
+// leaves a partially written file behind. The caller must hold the lock.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	revoked := make([]dbRevocation, 0, len(s.revoked))
+	for jti, expiresAt := range s.revoked {
+		revoked = append(revoked, dbRevocation{ID: jti, ExpiresAt: expiresAt})
+	}
+	sort.Slice(revoked, func(i, j int) bool {
+		return revoked[i].ID < revoked[j].ID
+	})
+
+	data, err := json.MarshalIndent(revoked, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding database file: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("creating database directory: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing database file: %w", err)
+	}
+
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("replacing database file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,276 @@
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// rule checks a single field value. It returns a message describing the
+// failure, or an empty string when the value is good. The error is reserved
+// for rules that are declared incorrectly on the model.
+type rule func(parent reflect.Value, field reflect.Value, param string) (string, error)
+
+// rules is the set of rules that can be used in a validate tag.
+var rules map[string]rule
+
+func init() {
+	rules = map[string]rule{
+		"required": required,
+		"email":    email,
+		"uuid":     isUUID,
+		"min":      minimum,
+		"max":      maximum,
+		"len":      length,
+		"oneof":    oneOf,
+		"eqfield":  eqField,
+	}
+}
+
+// required checks the field has a value. Pointers must be non-nil and
+// strings, slices and maps must be non-empty.
+func required(_ reflect.Value, field reflect.Value, _ string) (string, error) {
+	switch field.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if field.IsNil() {
+			return "is a required field", nil
+		}
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		if field.Len() == 0 {
+			return "is a required field", nil
+		}
+	default:
+		if field.IsZero() {
+			return "is a required field", nil
+		}
+	}
+
+	return "", nil
+}
+
+// email checks the field is a bare email address.
+func email(_ reflect.Value, field reflect.Value, _ string) (string, error) {
+	s, err := stringValue(field)
+	if err != nil {
+		return "", err
+	}
+
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != s {
+		return "must be a valid email address", nil
+	}
+
+	return "", nil
+}
+
+// isUUID checks the field is a UUID string.
+func isUUID(_ reflect.Value, field reflect.Value, _ string) (string, error) {
+	s, err := stringValue(field)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := uuid.Parse(s); err != nil {
+		return "must be a valid UUID", nil
+	}
+
+	return "", nil
+}
+
+// minimum checks the length of a string or collection, or the value of a
+// number, is at least param.
+func minimum(_ reflect.Value, field reflect.Value, param string) (string, error) {
+	n, isLen, err := size(field, param)
+	if err != nil {
+		return "", err
+	}
+
+	limit, _ := strconv.ParseFloat(param, 64)
+	if n < limit {
+		if isLen {
+			return fmt.Sprintf("must be at least %s %s in length", param, unit(field)), nil
+		}
+		return fmt.Sprintf("must be %s or greater", param), nil
+	}
+
+	return "", nil
+}
+
+// maximum checks the length of a string or collection, or the value of a
+// number, is at most param.
+func maximum(_ reflect.Value, field reflect.Value, param string) (string, error) {
+	n, isLen, err := size(field, param)
+	if err != nil {
+		return "", err
+	}
+
+	limit, _ := strconv.ParseFloat(param, 64)
+	if n > limit {
+		if isLen {
+			return fmt.Sprintf("must be a maximum of %s %s in length", param, unit(field)), nil
+		}
+		return fmt.Sprintf("must be %s or less", param), nil
+	}
+
+	return "", nil
+}
+
+// length checks the length of a string or collection is exactly param.
+func length(_ reflect.Value, field reflect.Value, param string) (string, error) {
+	n, isLen, err := size(field, param)
+	if err != nil {
+		return "", err
+	}
+
+	if !isLen {
+		return "", fmt.Errorf("len requires a string or collection, got %s", field.Kind())
+	}
+
+	limit, _ := strconv.ParseFloat(param, 64)
+	if n != limit {
+		return fmt.Sprintf("must be %s %s in length", param, unit(field)), nil
+	}
+
+	return "", nil
+}
+
+// oneOf checks the field is one of a space separated list of values. For a
+// slice of strings every element is checked.
+func oneOf(_ reflect.Value, field reflect.Value, param string) (string, error) {
+	allowed := strings.Fields(param)
+	msg := fmt.Sprintf("must be one of [%s]", strings.Join(allowed, " "))
+
+	field = indirect(field)
+	if field.Kind() == reflect.Slice {
+		for i := 0; i < field.Len(); i++ {
+			s, err := stringValue(field.Index(i))
+			if err != nil {
+				return "", err
+			}
+			if !contains(allowed, s) {
+				return msg, nil
+			}
+		}
+		return "", nil
+	}
+
+	s, err := stringValue(field)
+	if err != nil {
+		return "", err
+	}
+	if !contains(allowed, s) {
+		return msg, nil
+	}
+
+	return "", nil
+}
+
+// eqField checks the field holds the same value as the named field of the
+// same struct. A nil pointer is treated as its zero value.
+func eqField(parent reflect.Value, field reflect.Value, param string) (string, error) {
+	sf, exists := parent.Type().FieldByName(param)
+	if !exists {
+		return "", fmt.Errorf("eqfield references unknown field %q", param)
+	}
+
+	a := indirect(field)
+	b := indirect(parent.FieldByIndex(sf.Index))
+	if !a.IsValid() {
+		a = reflect.Zero(field.Type().Elem())
+	}
+	if !b.IsValid() {
+		b = reflect.Zero(sf.Type.Elem())
+	}
+
+	if !a.Type().Comparable() || a.Type() != b.Type() {
+		return "", fmt.Errorf("eqfield can not compare with field %q", param)
+	}
+
+	if a.Interface() != b.Interface() {
+		return "must be equal to " + fieldName(sf), nil
+	}
+
+	return "", nil
+}
+
+// =============================================================================
+
+// indirect follows pointers to the underlying value. It returns the zero
+// reflect.Value for a nil pointer.
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// stringValue returns the string held by the field.
+func stringValue(field reflect.Value) (string, error) {
+	field = indirect(field)
+	if !field.IsValid() {
+		return "", nil
+	}
+
+	if field.Kind() != reflect.String {
+		return "", fmt.Errorf("rule requires a string, got %s", field.Kind())
+	}
+
+	return field.String(), nil
+}
+
+// size returns the length of a string or collection, or the value of a
+// number, along with which of the two it is.
+func size(field reflect.Value, param string) (float64, bool, error) {
+	if _, err := strconv.ParseFloat(param, 64); err != nil {
+		return 0, false, fmt.Errorf("invalid parameter %q", param)
+	}
+
+	field = indirect(field)
+	switch field.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(field.String())), true, nil
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(field.Len()), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(field.Int()), false, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(field.Uint()), false, nil
+	case reflect.Float32, reflect.Float64:
+		return field.Float(), false, nil
+	case reflect.Invalid:
+		return 0, true, nil
+	}
+
+	return 0, false, fmt.Errorf("can not measure a %s", field.Kind())
+}
+
+// unit returns the word used to describe the length of the field.
+func unit(field reflect.Value) string {
+	t := field.Type()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() == reflect.String {
+		return "characters"
+	}
+	return "items"
+}
+
+// contains reports if the value is in the list.
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
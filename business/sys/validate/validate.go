@@ -3,6 +3,9 @@ package validate
 
 import (
 	"errors"
+	"fmt"
+	"reflect"
+	"strings"
 )
 
 // ErrorResponse is the form used for API responses from failures in the API.
@@ -45,3 +48,106 @@ func GetRequestError(err error) *RequestError {
 	return re
 }
 
+
+// =============================================================================
+
+// Check validates the provided model against its declared tags. Rules are
+// listed in a `validate` struct tag separated by commas:
+//
+//	Name  string `json:"name" validate:"required,min=3"`
+//	Email string `json:"email" validate:"required,email"`
+//
+// Any failures are returned as FieldErrors keyed by the JSON field name.
+// Values that are not structs have nothing to check.
+func Check(val any) error {
+	rv := reflect.ValueOf(val)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return errors.New("validate: nil value")
+		}
+		rv = rv.Elem()
+	}
+
+	// Only structs can declare rules.
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields FieldErrors
+	if err := checkStruct(rv, &fields); err != nil {
+		return err
+	}
+
+	if len(fields) > 0 {
+		return fields
+	}
+
+	return nil
+}
+
+// checkStruct applies the rules declared on each field of the struct,
+// walking into embedded structs so their fields are reported flat.
+func checkStruct(rv reflect.Value, fields *FieldErrors) error {
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			if err := checkStruct(rv.Field(i), fields); err != nil {
+				return err
+			}
+			continue
+		}
+
+		tag := sf.Tag.Get("validate")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		name := fieldName(sf)
+		for _, rule := range strings.Split(tag, ",") {
+			rule, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+
+			if rule == "omitempty" {
+				if rv.Field(i).IsZero() {
+					break
+				}
+				continue
+			}
+
+			fn, exists := rules[rule]
+			if !exists {
+				return fmt.Errorf("validate: unknown rule %q on field %s", rule, sf.Name)
+			}
+
+			msg, err := fn(rv, rv.Field(i), param)
+			if err != nil {
+				return fmt.Errorf("validate: field %s: %w", sf.Name, err)
+			}
+
+			if msg != "" {
+				*fields = append(*fields, FieldError{
+					Field: name,
+					Error: name + " " + msg,
+				})
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// fieldName returns the name a client knows the field by, which is the name
+// in the JSON tag when one is provided.
+func fieldName(sf reflect.StructField) string {
+	if name, _, _ := strings.Cut(sf.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+
+	return strings.ToLower(sf.Name[:1]) + sf.Name[1:]
+}
@@ -0,0 +1,107 @@
+package validate_test
+
+import (
+	"testing"
+
+	"github.com/ardanlabs/service/business/sys/validate"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+type model struct {
+	Name     string   `json:"name" validate:"required,min=3"`
+	Email    string   `json:"email_address" validate:"required,email"`
+	Roles    []string `json:"roles" validate:"required,oneof=ADMIN USER"`
+	Age      int      `json:"age" validate:"omitempty,min=18,max=130"`
+	Nick     *string  `json:"nick" validate:"omitempty,max=5"`
+	Password string   `json:"password"`
+	Confirm  string   `json:"confirm" validate:"eqfield=Password"`
+}
+
+func Test_Check(t *testing.T) {
+	t.Log("Given the need to validate models with struct tags.")
+
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a valid model.", testID)
+		{
+			m := model{
+				Name:     "Bill",
+				Email:    "bill@ardanlabs.com",
+				Roles:    []string{"ADMIN", "USER"},
+				Password: "gophers",
+				Confirm:  "gophers",
+			}
+
+			if err := validate.Check(m); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould pass validation : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould pass validation.", success, testID)
+		}
+	}
+
+	{
+		testID := 1
+		t.Logf("\tTest %d:\tWhen handling an invalid model.", testID)
+		{
+			nick := "gopherface"
+			m := model{
+				Name:     "Bi",
+				Email:    "Bill <bill@ardanlabs.com>",
+				Roles:    []string{"ADMIN", "ROOT"},
+				Age:      12,
+				Nick:     &nick,
+				Password: "gophers",
+				Confirm:  "gopher",
+			}
+
+			err := validate.Check(&m)
+			if !validate.IsFieldErrors(err) {
+				t.Fatalf("\t%s\tTest %d:\tShould get back field errors : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back field errors.", success, testID)
+
+			exp := map[string]string{
+				"name":          "name must be at least 3 characters in length",
+				"email_address": "email_address must be a valid email address",
+				"roles":         "roles must be one of [ADMIN USER]",
+				"age":           "age must be 18 or greater",
+				"nick":          "nick must be a maximum of 5 characters in length",
+				"confirm":       "confirm must be equal to password",
+			}
+
+			got := validate.GetFieldErrors(err).Fields()
+			if len(got) != len(exp) {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, got)
+				t.Logf("\t\tTest %d:\tExp: %v", testID, exp)
+				t.Fatalf("\t%s\tTest %d:\tShould get an error for each bad field.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get an error for each bad field.", success, testID)
+
+			for field, msg := range exp {
+				if got[field] != msg {
+					t.Logf("\t\tTest %d:\tGot: %v", testID, got[field])
+					t.Logf("\t\tTest %d:\tExp: %v", testID, msg)
+					t.Fatalf("\t%s\tTest %d:\tShould get the expected message for %q.", failed, testID, field)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the expected messages keyed by JSON name.", success, testID)
+		}
+	}
+
+	{
+		testID := 2
+		t.Logf("\tTest %d:\tWhen handling a model with a missing required field.", testID)
+		{
+			err := validate.Check(model{Email: "bill@ardanlabs.com", Roles: []string{"USER"}})
+
+			if got := validate.GetFieldErrors(err).Fields()["name"]; got != "name is a required field" {
+				t.Fatalf("\t%s\tTest %d:\tShould stop at the first failed rule : %q.", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould stop at the first failed rule.", success, testID)
+		}
+	}
+}
@@ -1,41 +1,101 @@
-package auth
+// Package keystore implements the auth.KeyLookup interface. This implements
+// an in-memory keystore for JWT support.
+//
+// Keys go through a lifecycle to support rotation. A key is added, then
+// activated so it is used to sign new tokens. When another key is activated
+// the previous one is retired at a given time, and until then it remains
+// valid for verifying the tokens it signed.
+package keystore
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"fmt"
-	"github.com/golang-jwt/jwt/v4"
 	"io"
 	"io/fs"
 	"path"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/auth"
+)
+
+// Set of errors returned by the key store.
+var (
+	ErrKeyNotFound = errors.New("kid lookup failed")
+	ErrKeyRetired  = errors.New("key has been retired")
+	ErrNoActiveKey = errors.New("no active key")
 )
 
-//Keystore
+// AlgorithmHeader is the optional PEM header that sets the algorithm a key
+// signs with. Without it the default algorithm for the key type is used.
+const AlgorithmHeader = "Algorithm"
+
+// key holds a private key, the algorithm it signs with and the time it stops
+// being valid. A zero retire time means the key isn't scheduled for
+// retirement.
+type key struct {
+	signer    crypto.Signer
+	algorithm string
+	retireAt  time.Time
+}
+
+// retired reports if the key is no longer valid at the specified time.
+func (k key) retired(now time.Time) bool {
+	return !k.retireAt.IsZero() && !now.Before(k.retireAt)
+}
+
+// KeyStore represents an in memory store implementation of the
+// KeyLookup interface for use with the auth package.
 type KeyStore struct {
-	mu sync.RWMutex
-	store map[string]*rsa.PrivateKey
+	mu        sync.RWMutex
+	store     map[string]key
+	activeKID string
 }
 
+// New constructs an empty KeyStore ready for use.
 func New() *KeyStore {
 	return &KeyStore{
-		store: map[string]*rsa.PrivateKey{},
+		store: make(map[string]key),
 	}
 }
-//NewMap constructs a KeyStore wth an initial set of keys
-func NewMap(store map[string]*rsa.PrivateKey) *KeyStore {
-	return &KeyStore{store: store}
+
+// NewMap constructs a KeyStore with an initial set of keys. Each key signs
+// with the default algorithm for its type.
+func NewMap(store map[string]crypto.Signer) (*KeyStore, error) {
+	ks := New()
+	for kid, signer := range store {
+		if err := ks.Add(kid, signer, ""); err != nil {
+			return nil, err
+		}
+	}
+	return ks, nil
 }
 
-//NewFS constructs a KeyStore based on a set of PEM files rooted inside
-//of a directory. Te name of each PEM file will be used as the key id.
-//Example: keystore.NewFS(os.DirFS("/zarf/keys"))
-//Example: /zarf/keys/54bb2165-71e1-41a6-af3e-7da4a0e1e2c1.pem
+// NewFS constructs a KeyStore based on a set of PEM files rooted inside
+// of a directory. The name of each PEM file will be used as the key id.
+// RSA, ECDSA and Ed25519 keys are supported.
+// Example: keystore.NewFS(os.DirFS("/zarf/keys"))
+// Example: /zarf/keys/54bb2165-71e1-41a6-af3e-7da4a0e1e2c1.pem
 func NewFS(fsys fs.FS) (*KeyStore, error) {
-	ks := KeyStore{
-		store: make(map[string]*rsa.PrivateKey),
+	ks := New()
+	if _, err := ks.LoadFS(fsys); err != nil {
+		return nil, err
 	}
+	return ks, nil
+}
+
+// LoadFS adds the keys found in the PEM files rooted inside of a directory
+// that are not already in the store. It returns the key ids that were added
+// so new keys can be picked up at runtime without a restart.
+func (ks *KeyStore) LoadFS(fsys fs.FS) ([]string, error) {
+	keys := make(map[string]key)
 
 	fn := func(filename string, dirEntry fs.DirEntry, err error) error {
 		if err != nil {
@@ -52,63 +112,274 @@ func NewFS(fsys fs.FS) (*KeyStore, error) {
 
 		file, err := fsys.Open(filename)
 		if err != nil {
-			return fmt.Errorf("opening key file %w", err)
+			return fmt.Errorf("opening key file: %w", err)
 		}
 		defer file.Close()
 
-		//limit PEM file sieze to 1 megabyte. This should be reasonable for
-		//almost any PEM file and prevents shenanigans like linking the file
-		//to /dev/random or something like that.
+		// limit PEM file size to 1 megabyte. This should be reasonable for
+		// almost any PEM file and prevents shenanigans like linking the file
+		// to /dev/random or something like that.
 		privatePEM, err := io.ReadAll(io.LimitReader(file, 1024*1024))
-		if err!=nil{
+		if err != nil {
 			return fmt.Errorf("reading auth private key: %w", err)
 		}
 
-		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
-		if err!=nil{
-			return fmt.Errorf("parsing auth private key: %w", err)
+		k, err := parsePEM(privatePEM)
+		if err != nil {
+			return fmt.Errorf("parsing auth private key %s: %w", filename, err)
 		}
-		ks.store[strings.TrimSuffix(dirEntry.Name(), ".pem")] = privateKey
+
+		keys[strings.TrimSuffix(dirEntry.Name(), ".pem")] = k
 		return nil
 	}
-	if err := fs.WalkDir(fsys, ".", fn); err !=nil {
+
+	if err := fs.WalkDir(fsys, ".", fn); err != nil {
 		return nil, fmt.Errorf("walking directory: %w", err)
 	}
-	return &ks, nil
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	var added []string
+	for kid, k := range keys {
+		if _, exists := ks.store[kid]; exists {
+			continue
+		}
+		ks.store[kid] = k
+		added = append(added, kid)
+	}
+
+	return added, nil
+}
+
+// Add adds a private key to the store so tokens signed with it can be
+// verified. An empty algorithm selects the default for the key type. Adding
+// a key that already exists replaces it and clears any retirement.
+func (ks *KeyStore) Add(kid string, signer crypto.Signer, algorithm string) error {
+	k, err := newKey(signer, algorithm)
+	if err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.store[kid] = k
+	return nil
 }
 
-func (ks *KeyStore) Add(kid string) {
+// Remove removes a key from the store. The active key can't be removed.
+func (ks *KeyStore) Remove(kid string) error {
 	ks.mu.Lock()
 	defer ks.mu.Unlock()
+
+	if kid == ks.activeKID {
+		return fmt.Errorf("removing active key %q", kid)
+	}
+
 	delete(ks.store, kid)
+	return nil
 }
 
-func (ks *KeyStore) Remove(kid string)  {
+// Activate promotes a key to be the one used to sign new tokens. A retired
+// key can't be activated.
+func (ks *KeyStore) Activate(kid string) error {
 	ks.mu.Lock()
 	defer ks.mu.Unlock()
-	delete(ks.store, kid)
+
+	return ks.activate(kid)
 }
 
-//PrivateKey searches the key store for a given kid and returns
-//the private key
-func (ks *KeyStore) PrivateKeyPEM(kid string) (*rsa.PrivateKey, error) {
+// Rotate activates a key and schedules the previously active key to retire
+// at the specified time. The retirement time should be after the expiry of
+// the last token the previous key signed.
+func (ks *KeyStore) Rotate(kid string, retireAt time.Time) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	previous := ks.activeKID
+	if err := ks.activate(kid); err != nil {
+		return err
+	}
+
+	if previous == "" || previous == kid {
+		return nil
+	}
+
+	return ks.retire(previous, retireAt)
+}
+
+// Retire schedules a key to stop being valid at the specified time. The
+// active key can't be retired, activate another key first.
+func (ks *KeyStore) Retire(kid string, retireAt time.Time) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	return ks.retire(kid, retireAt)
+}
 
-	privateKey, found := ks.store[kid]
+// activate makes the kid the active key. The caller must hold the lock.
+func (ks *KeyStore) activate(kid string) error {
+	k, found := ks.store[kid]
 	if !found {
-		return nil, errors.New("kid lookup failed")
+		return ErrKeyNotFound
 	}
-	return privateKey, nil
+
+	if k.retired(time.Now()) {
+		return ErrKeyRetired
+	}
+
+	k.retireAt = time.Time{}
+	ks.store[kid] = k
+	ks.activeKID = kid
+
+	return nil
 }
 
-//searches the key store for a given kid and returns the public key
-func (ks *KeyStore) PublicKeyPEM(kid string) (*rsa.PublicKey, error) {
+// retire sets the retirement time of the kid. The caller must hold the lock.
+func (ks *KeyStore) retire(kid string, retireAt time.Time) error {
+	k, found := ks.store[kid]
+	if !found {
+		return ErrKeyNotFound
+	}
 
-	privateKey, found := ks.store[kid]
+	if kid == ks.activeKID {
+		return fmt.Errorf("retiring active key %q", kid)
+	}
+
+	k.retireAt = retireAt
+	ks.store[kid] = k
+
+	return nil
+}
+
+// ActiveKID returns the id of the key currently used to sign tokens.
+func (ks *KeyStore) ActiveKID() (string, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	if ks.activeKID == "" {
+		return "", ErrNoActiveKey
+	}
+
+	return ks.activeKID, nil
+}
+
+// KIDs returns the ids of the keys that have not retired.
+func (ks *KeyStore) KIDs() []string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	now := time.Now()
+
+	kids := make([]string, 0, len(ks.store))
+	for kid, k := range ks.store {
+		if !k.retired(now) {
+			kids = append(kids, kid)
+		}
+	}
+
+	return kids
+}
+
+// PrivateKeyPEM searches the key store for a given kid and returns
+// the private key.
+func (ks *KeyStore) PrivateKeyPEM(kid string) (crypto.Signer, error) {
+	k, err := ks.lookup(kid)
+	if err != nil {
+		return nil, err
+	}
+	return k.signer, nil
+}
+
+// PublicKeyPEM searches the key store for a given kid and returns
+// the public key.
+func (ks *KeyStore) PublicKeyPEM(kid string) (crypto.PublicKey, error) {
+	k, err := ks.lookup(kid)
+	if err != nil {
+		return nil, err
+	}
+	return k.signer.Public(), nil
+}
+
+// Algorithm returns the algorithm the key signs with.
+func (ks *KeyStore) Algorithm(kid string) (string, error) {
+	k, err := ks.lookup(kid)
+	if err != nil {
+		return "", err
+	}
+	return k.algorithm, nil
+}
+
+// lookup returns the key for the kid if it hasn't been retired.
+func (ks *KeyStore) lookup(kid string) (key, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	k, found := ks.store[kid]
 	if !found {
-		return nil, errors.New("kid lookup failed")
+		return key{}, ErrKeyNotFound
+	}
+
+	if k.retired(time.Now()) {
+		return key{}, ErrKeyRetired
+	}
+
+	return k, nil
+}
+
+// newKey validates the algorithm can be used with the key, defaulting it
+// based on the key type.
+func newKey(signer crypto.Signer, algorithm string) (key, error) {
+	if algorithm == "" {
+		var err error
+		if algorithm, err = auth.DefaultAlgorithm(signer.Public()); err != nil {
+			return key{}, err
+		}
 	}
-	return &privateKey.PublicKey, nil
+
+	if err := auth.CheckAlgorithm(algorithm, signer.Public()); err != nil {
+		return key{}, err
+	}
+
+	return key{signer: signer, algorithm: algorithm}, nil
 }
 
+// parsePEM decodes a PEM encoded private key. PKCS #1 and SEC 1 keys are
+// supported along with any RSA, ECDSA or Ed25519 key in PKCS #8 form.
+func parsePEM(data []byte) (key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return key{}, errors.New("no PEM block found")
+	}
+
+	var privateKey any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		privateKey, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return key{}, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return key{}, err
+	}
 
+	var signer crypto.Signer
+	switch pk := privateKey.(type) {
+	case *rsa.PrivateKey:
+		signer = pk
+	case *ecdsa.PrivateKey:
+		signer = pk
+	case ed25519.PrivateKey:
+		signer = pk
+	default:
+		return key{}, fmt.Errorf("unsupported private key type %T", privateKey)
+	}
 
+	return newKey(signer, block.Headers[AlgorithmHeader])
+}
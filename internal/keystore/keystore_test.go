@@ -0,0 +1,271 @@
+package keystore_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/internal/keystore"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_Rotation(t *testing.T) {
+	t.Log("Given the need to rotate the keys used to sign tokens.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a new key is activated.", testID)
+		{
+			ks := keystore.New()
+			ks.Add("old", generateKey(t), "")
+			ks.Add("new", generateKey(t), "")
+
+			if err := ks.Activate("old"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to activate the old key : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to activate the old key.", success, testID)
+
+			a, err := auth.New("", ks)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create authenticator : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create authenticator.", success, testID)
+
+			oldToken := generateToken(t, a)
+
+			retireAt := time.Now().Add(time.Hour)
+			if err := ks.Rotate("new", retireAt); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to rotate to the new key : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to rotate to the new key.", success, testID)
+
+			newToken := generateToken(t, a)
+			if kid := tokenKID(t, newToken); kid != "new" {
+				t.Fatalf("\t%s\tTest %d:\tShould sign new tokens with the new key : got %q.", failed, testID, kid)
+			}
+			t.Logf("\t%s\tTest %d:\tShould sign new tokens with the new key.", success, testID)
+
+			if _, err := a.ValidateToken(context.Background(), oldToken); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould accept tokens of the retiring key : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept tokens of the retiring key.", success, testID)
+
+			if err := ks.Retire("new", retireAt); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to retire the active key.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to retire the active key.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen a key has retired.", testID)
+		{
+			ks := keystore.New()
+			ks.Add("old", generateKey(t), "")
+			ks.Add("new", generateKey(t), "")
+
+			if err := ks.Activate("old"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to activate the old key : %v.", failed, testID, err)
+			}
+
+			a, err := auth.New("", ks)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create authenticator : %v.", failed, testID, err)
+			}
+
+			oldToken := generateToken(t, a)
+
+			if err := ks.Rotate("new", time.Now()); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to rotate to the new key : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to rotate to the new key.", success, testID)
+
+			if _, err := a.ValidateToken(context.Background(), oldToken); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould reject tokens of the retired key.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject tokens of the retired key.", success, testID)
+
+			if _, err := ks.PublicKeyPEM("old"); !errors.Is(err, keystore.ErrKeyRetired) {
+				t.Fatalf("\t%s\tTest %d:\tShould report the key as retired : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould report the key as retired.", success, testID)
+
+			if err := ks.Activate("old"); !errors.Is(err, keystore.ErrKeyRetired) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to activate the retired key : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to activate the retired key.", success, testID)
+		}
+	}
+}
+
+func Test_Algorithms(t *testing.T) {
+	t.Log("Given the need to sign tokens with different algorithms.")
+	{
+		rsaKey := generateKey(t)
+		ec256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		ec384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+		fsys := fstest.MapFS{
+			"rs256.pem": {Data: encodePEM(t, rsaKey, "")},
+			"ps256.pem": {Data: encodePEM(t, rsaKey, auth.AlgPS256)},
+			"es256.pem": {Data: encodePEM(t, ec256, "")},
+			"es384.pem": {Data: encodePEM(t, ec384, "")},
+			"eddsa.pem": {Data: encodePEM(t, edKey, "")},
+		}
+
+		ks, err := keystore.NewFS(fsys)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to load the keys : %v.", failed, err)
+		}
+		t.Logf("\t%s\tShould be able to load the keys.", success)
+
+		tt := []struct {
+			kid string
+			alg string
+		}{
+			{"rs256", auth.AlgRS256},
+			{"ps256", auth.AlgPS256},
+			{"es256", auth.AlgES256},
+			{"es384", auth.AlgES384},
+			{"eddsa", auth.AlgEdDSA},
+		}
+
+		for testID, tst := range tt {
+			t.Logf("\tTest %d:\tWhen signing with a %s key.", testID, tst.alg)
+			{
+				if err := ks.Activate(tst.kid); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to activate the key : %v.", failed, testID, err)
+				}
+
+				a, err := auth.New("", ks)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to create authenticator : %v.", failed, testID, err)
+				}
+
+				token := generateToken(t, a)
+
+				parsed, _, err := jwt.NewParser().ParseUnverified(token, &auth.Claims{})
+				if err != nil || parsed.Method.Alg() != tst.alg {
+					t.Fatalf("\t%s\tTest %d:\tShould sign with %s : %v.", failed, testID, tst.alg, parsed.Method.Alg())
+				}
+				t.Logf("\t%s\tTest %d:\tShould sign with %s.", success, testID, tst.alg)
+
+				if _, err := a.ValidateToken(context.Background(), token); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to validate the token : %v.", failed, testID, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be able to validate the token.", success, testID)
+
+				set, err := a.JWKS()
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to build the key set : %v.", failed, testID, err)
+				}
+
+				for _, jwk := range set.Keys {
+					publicKey, err := jwk.PublicKey()
+					if err != nil {
+						t.Fatalf("\t%s\tTest %d:\tShould be able to decode key %q : %v.", failed, testID, jwk.KeyID, err)
+					}
+					expected, _ := ks.PublicKeyPEM(jwk.KeyID)
+					if !expected.(interface{ Equal(crypto.PublicKey) bool }).Equal(publicKey) {
+						t.Fatalf("\t%s\tTest %d:\tShould publish key %q.", failed, testID, jwk.KeyID)
+					}
+				}
+				t.Logf("\t%s\tTest %d:\tShould publish every key.", success, testID)
+			}
+		}
+
+		testID := len(tt)
+		t.Logf("\tTest %d:\tWhen a token uses another algorithm than its key.", testID)
+		{
+			if err := ks.Activate("ps256"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to activate the key : %v.", failed, testID, err)
+			}
+
+			a, err := auth.New("", ks)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create authenticator : %v.", failed, testID, err)
+			}
+
+			token := jwt.NewWithClaims(jwt.SigningMethodRS256, auth.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+			})
+			token.Header["kid"] = "ps256"
+
+			str, err := token.SignedString(rsaKey)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to sign the token : %v.", failed, testID, err)
+			}
+
+			if _, err := a.ValidateToken(context.Background(), str); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the token.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the token.", success, testID)
+		}
+	}
+}
+
+// =============================================================================
+
+func encodePEM(t *testing.T, privateKey any, alg string) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("marshaling key: %v", err)
+	}
+
+	block := pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	if alg != "" {
+		block.Headers = map[string]string{keystore.AlgorithmHeader: alg}
+	}
+
+	return pem.EncodeToMemory(&block)
+}
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	return privateKey
+}
+
+func generateToken(t *testing.T, a *auth.Auth) string {
+	claims := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+		Roles: []string{auth.RoleAdmin},
+	}
+
+	token, err := a.GenerateToken(claims)
+	if err != nil {
+		t.Fatalf("generating token: %v", err)
+	}
+	return token
+}
+
+func tokenKID(t *testing.T, token string) string {
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &auth.Claims{})
+	if err != nil {
+		t.Fatalf("parsing token: %v", err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
@@ -0,0 +1,230 @@
+package keystore
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/auth"
+)
+
+// ErrVerifyOnly is returned when a private key is requested from a key
+// lookup that can only verify tokens.
+var ErrVerifyOnly = errors.New("key lookup can only verify tokens")
+
+// RemoteConfig declares where to fetch a JWKS document from and how long
+// the keys are cached.
+type RemoteConfig struct {
+	URL    string
+	Client *http.Client
+
+	// TTL is how long the fetched keys are used before the document is
+	// fetched again.
+	TTL time.Duration
+
+	// MinRefreshInterval limits how often a token with an unknown kid can
+	// cause the document to be fetched again.
+	MinRefreshInterval time.Duration
+
+	// FetchTimeout limits how long fetching the document may take.
+	FetchTimeout time.Duration
+}
+
+// Remote implements the auth.KeyLookup interface using the public keys
+// published by an identity provider as a JWKS document. It can only verify
+// tokens.
+type Remote struct {
+	cfg RemoteConfig
+
+	mu          sync.Mutex
+	keys        map[string]remoteKey
+	expires     time.Time
+	lastAttempt time.Time
+	inflight    *refreshCall
+}
+
+// refreshCall is a fetch of the document in progress. Lookups that need
+// its result wait for done instead of fetching the document themselves.
+type refreshCall struct {
+	done chan struct{}
+	err  error
+}
+
+// NewRemote constructs a Remote key lookup. The document is fetched the
+// first time a key is looked up.
+func NewRemote(cfg RemoteConfig) *Remote {
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Hour
+	}
+	if cfg.FetchTimeout <= 0 {
+		cfg.FetchTimeout = 10 * time.Second
+	}
+
+	return &Remote{
+		cfg:  cfg,
+		keys: make(map[string]remoteKey),
+	}
+}
+
+// remoteKey is a public key from the document and the algorithm it is
+// published for, which may be empty.
+type remoteKey struct {
+	publicKey crypto.PublicKey
+	algorithm string
+}
+
+// PrivateKeyPEM always fails since the private keys are held by the
+// identity provider.
+func (r *Remote) PrivateKeyPEM(kid string) (crypto.Signer, error) {
+	return nil, ErrVerifyOnly
+}
+
+// PublicKeyPEM returns the public key for the kid. The document is fetched
+// again when the cache has expired or the kid is unknown, but no more than
+// once per minimum refresh interval. A known key is returned right away
+// while an expired cache is refreshed in the background, only a lookup for
+// an unknown kid waits for the fetch. If fetching fails the keys already
+// cached keep being used.
+func (r *Remote) PublicKeyPEM(kid string) (crypto.PublicKey, error) {
+	r.mu.Lock()
+
+	now := time.Now()
+	k, found := r.keys[kid]
+
+	var call *refreshCall
+	if (!found || now.After(r.expires)) && (r.inflight != nil || now.Sub(r.lastAttempt) >= r.cfg.MinRefreshInterval) {
+		call = r.startRefresh(now)
+	}
+
+	r.mu.Unlock()
+
+	if found {
+		return k.publicKey, nil
+	}
+
+	if call == nil {
+		return nil, ErrKeyNotFound
+	}
+
+	<-call.done
+
+	r.mu.Lock()
+	k, found = r.keys[kid]
+	r.mu.Unlock()
+
+	switch {
+	case found:
+		return k.publicKey, nil
+	case call.err != nil:
+		return nil, fmt.Errorf("refreshing keys: %w", call.err)
+	}
+
+	return nil, ErrKeyNotFound
+}
+
+// Algorithm returns the algorithm the key is published for. The keys are
+// not fetched, the key must have been looked up first.
+func (r *Remote) Algorithm(kid string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k, found := r.keys[kid]
+	if !found {
+		return "", ErrKeyNotFound
+	}
+
+	return k.algorithm, nil
+}
+
+// startRefresh returns the fetch in progress or starts a new one. The
+// caller must hold the lock.
+func (r *Remote) startRefresh(now time.Time) *refreshCall {
+	if r.inflight != nil {
+		return r.inflight
+	}
+
+	call := refreshCall{done: make(chan struct{})}
+	r.inflight = &call
+	r.lastAttempt = now
+
+	go r.refresh(&call)
+
+	return &call
+}
+
+// refresh fetches the document without holding the lock, so lookups of
+// cached keys never wait on the identity provider, then replaces the
+// cached keys.
+func (r *Remote) refresh(call *refreshCall) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.cfg.FetchTimeout)
+	defer cancel()
+
+	keys, err := r.fetch(ctx)
+
+	r.mu.Lock()
+	if err == nil {
+		r.keys = keys
+		r.expires = time.Now().Add(r.cfg.TTL)
+	}
+	r.inflight = nil
+	r.mu.Unlock()
+
+	call.err = err
+	close(call.done)
+}
+
+// fetch downloads and decodes the JWKS document. Keys that can't be used to
+// verify signatures are skipped.
+func (r *Remote) fetch(ctx context.Context) (map[string]remoteKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.cfg.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.cfg.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching jwks: unexpected status %d", resp.StatusCode)
+	}
+
+	// limit the document to 1 megabyte, which is far more than any
+	// reasonable key set needs.
+	var set auth.JWKSet
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1024*1024)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("decoding jwks: %w", err)
+	}
+
+	keys := make(map[string]remoteKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.KeyID == "" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+
+		publicKey, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+
+		if jwk.Algorithm != "" && auth.CheckAlgorithm(jwk.Algorithm, publicKey) != nil {
+			continue
+		}
+
+		keys[jwk.KeyID] = remoteKey{publicKey: publicKey, algorithm: jwk.Algorithm}
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,224 @@
+package keystore_test
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/auth"
+	"github.com/ardanlabs/service/internal/keystore"
+)
+
+// provider serves a JWKS document like an identity provider and counts how
+// often it is fetched.
+type provider struct {
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetches int32
+}
+
+func (p *provider) add(kid string, publicKey *rsa.PublicKey) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys[kid] = publicKey
+}
+
+func (p *provider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&p.fetches, 1)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var set auth.JWKSet
+	for kid, publicKey := range p.keys {
+		jwk, _ := auth.NewJWK(kid, auth.AlgRS256, publicKey)
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(set)
+}
+
+func Test_Remote(t *testing.T) {
+	t.Log("Given the need to verify tokens with keys published by an identity provider.")
+	{
+		first := generateKey(t)
+		p := provider{keys: map[string]*rsa.PublicKey{"first": &first.PublicKey}}
+		srv := httptest.NewServer(&p)
+		defer srv.Close()
+
+		testID := 0
+		t.Logf("\tTest %d:\tWhen looking up keys.", testID)
+		{
+			r := keystore.NewRemote(keystore.RemoteConfig{
+				URL:                srv.URL,
+				TTL:                time.Hour,
+				MinRefreshInterval: time.Hour,
+			})
+
+			publicKey, err := r.PublicKeyPEM("first")
+			if err != nil || !first.PublicKey.Equal(publicKey) {
+				t.Fatalf("\t%s\tTest %d:\tShould get the published key : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the published key.", success, testID)
+
+			r.PublicKeyPEM("first")
+			if n := atomic.LoadInt32(&p.fetches); n != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould cache the keys : fetched %d times.", failed, testID, n)
+			}
+			t.Logf("\t%s\tTest %d:\tShould cache the keys.", success, testID)
+
+			for i := 0; i < 3; i++ {
+				if _, err := r.PublicKeyPEM("unknown"); !errors.Is(err, keystore.ErrKeyNotFound) {
+					t.Fatalf("\t%s\tTest %d:\tShould not find an unknown key : %v.", failed, testID, err)
+				}
+			}
+			if n := atomic.LoadInt32(&p.fetches); n != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould rate limit refreshes for unknown keys : fetched %d times.", failed, testID, n)
+			}
+			t.Logf("\t%s\tTest %d:\tShould rate limit refreshes for unknown keys.", success, testID)
+
+			if _, err := r.PrivateKeyPEM("first"); !errors.Is(err, keystore.ErrVerifyOnly) {
+				t.Fatalf("\t%s\tTest %d:\tShould not provide private keys : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not provide private keys.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen the identity provider publishes a new key.", testID)
+		{
+			atomic.StoreInt32(&p.fetches, 0)
+
+			r := keystore.NewRemote(keystore.RemoteConfig{
+				URL:                srv.URL,
+				TTL:                time.Hour,
+				MinRefreshInterval: 0,
+			})
+
+			if _, err := r.PublicKeyPEM("first"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould get the published key : %v.", failed, testID, err)
+			}
+
+			second := generateKey(t)
+			p.add("second", &second.PublicKey)
+
+			publicKey, err := r.PublicKeyPEM("second")
+			if err != nil || !second.PublicKey.Equal(publicKey) {
+				t.Fatalf("\t%s\tTest %d:\tShould refresh to find the new key : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refresh to find the new key.", success, testID)
+
+			if n := atomic.LoadInt32(&p.fetches); n != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould fetch once per refresh : fetched %d times.", failed, testID, n)
+			}
+			t.Logf("\t%s\tTest %d:\tShould fetch once per refresh.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen the cached keys expire.", testID)
+		{
+			atomic.StoreInt32(&p.fetches, 0)
+
+			r := keystore.NewRemote(keystore.RemoteConfig{
+				URL:                srv.URL,
+				TTL:                50 * time.Millisecond,
+				MinRefreshInterval: 0,
+			})
+
+			r.PublicKeyPEM("first")
+			time.Sleep(100 * time.Millisecond)
+			srv.Close()
+
+			if _, err := r.PublicKeyPEM("first"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould keep using cached keys when the provider is down : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep using cached keys when the provider is down.", success, testID)
+
+			if n := atomic.LoadInt32(&p.fetches); n != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould only reach the provider before it is down : fetched %d times.", failed, testID, n)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only reach the provider before it is down.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen the identity provider is slow to answer.", testID)
+		{
+			slow := provider{keys: map[string]*rsa.PublicKey{"first": &first.PublicKey}}
+			started := make(chan struct{})
+			release := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.LoadInt32(&slow.fetches) > 0 {
+					close(started)
+					<-release
+				}
+				slow.ServeHTTP(w, r)
+			}))
+			defer srv.Close()
+
+			r := keystore.NewRemote(keystore.RemoteConfig{
+				URL:                srv.URL,
+				TTL:                50 * time.Millisecond,
+				MinRefreshInterval: 0,
+				FetchTimeout:       5 * time.Second,
+			})
+
+			r.PublicKeyPEM("first")
+			time.Sleep(100 * time.Millisecond)
+
+			second := generateKey(t)
+			var wg sync.WaitGroup
+			errs := make(chan error, 5)
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					publicKey, err := r.PublicKeyPEM("second")
+					if err == nil && !second.PublicKey.Equal(publicKey) {
+						err = errors.New("wrong key")
+					}
+					errs <- err
+				}()
+			}
+			<-started
+
+			cached := make(chan error, 1)
+			go func() {
+				_, err := r.PublicKeyPEM("first")
+				cached <- err
+			}()
+
+			select {
+			case err := <-cached:
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould get a cached key while the keys are fetched : %v.", failed, testID, err)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("\t%s\tTest %d:\tShould get a cached key while the keys are fetched.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get a cached key while the keys are fetched.", success, testID)
+
+			slow.add("second", &second.PublicKey)
+			close(release)
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould wait for the fetch to find a new key : %v.", failed, testID, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould wait for the fetch to find a new key.", success, testID)
+
+			if n := atomic.LoadInt32(&slow.fetches); n != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould share one fetch between lookups : fetched %d times.", failed, testID, n)
+			}
+			t.Logf("\t%s\tTest %d:\tShould share one fetch between lookups.", success, testID)
+		}
+	}
+}
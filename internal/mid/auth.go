@@ -4,32 +4,50 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"strings"
+
 	"github.com/ardanlabs/service/business/sys/auth"
 	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/internal/platform/logger"
 	"github.com/ardanlabs/service/internal/platform/web"
-	"net/http"
-	"strings"
 )
 
-// Authenticate validates a JWT from the `Authorization` header.
-func Authenticate(a *auth.Auth) web.Middleware {
+// Authenticate validates a JWT from the `Authorization` header, or an API key
+// from the `X-API-Key` header. The reason a token or key is rejected is
+// logged, the client only learns the credentials are invalid.
+func Authenticate(log *logger.Logger, a *auth.Auth) web.Middleware {
 	//This is the actual middleware function to be executed
 	m := func(handler web.Handler) web.Handler {
 		//Create the handler that will be attached in the middleware chain
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			//API keys produce the same claims as a token
+			if key := r.Header.Get("X-API-Key"); key != "" {
+				claims, err := a.ValidateAPIKey(ctx, key)
+				if err != nil {
+					log.Warn(ctx, "authenticate", "status", "api key rejected", "reason", err)
+					return validate.NewRequestError(errors.New("invalid api key"), http.StatusUnauthorized)
+				}
+
+				ctx = auth.SetClaims(ctx, claims)
+
+				return handler(ctx, w, r)
+			}
+
 			//Expecting: bearer <token>
 			authStr := r.Header.Get("authorization")
 
 			parts := strings.Split(authStr, " ")
-			if len(parts) !=2 || strings.ToLower(parts[0]) != "bearer" {
+			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
 				err := errors.New("expected authorization header format: bearer <token>")
 				return validate.NewRequestError(err, http.StatusUnauthorized)
 			}
 
 			//Validate the token is signed by us
-			claims, err := a.ValidateToken(parts[1])
+			claims, err := a.ValidateToken(ctx, parts[1])
 			if err != nil {
-				return validate.NewRequestError(err, http.StatusUnauthorized)
+				log.Warn(ctx, "authenticate", "status", "token rejected", "reason", err)
+				return validate.NewRequestError(errors.New("invalid token"), http.StatusUnauthorized)
 			}
 
 			//Add claims to the context so they can be retrived later
@@ -58,14 +76,74 @@ func Authorize(roles ...string) web.Middleware {
 				return validate.NewRequestError(
 					fmt.Errorf("you are not authorized for that action, no claims"),
 					http.StatusForbidden,
-					)
+				)
 			}
 
 			if !claims.Authorized(roles...) {
 				return validate.NewRequestError(
 					fmt.Errorf("authorize: you are not authorized for that action, claims[%v] rule[%v]", claims.Roles, roles),
 					http.StatusForbidden,
-					)
+				)
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// RequirePermission validates that the roles of an authenticated user grant
+// every one of the permissions, according to the policy of the Auth.
+func RequirePermission(a *auth.Auth, perms ...string) web.Middleware {
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			claims := auth.GetClaims(ctx)
+			if claims.Subject == "" {
+				return validate.NewRequestError(
+					errors.New("you are not authorized for that action, no claims"),
+					http.StatusForbidden,
+				)
+			}
+
+			if !a.HasPermissions(claims, perms...) {
+				return validate.NewRequestError(
+					fmt.Errorf("you are not authorized for that action, claims[%v] permissions[%v]", claims.Roles, perms),
+					http.StatusForbidden,
+				)
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// RequireOwner validates that an authenticated user owns the resource named
+// by the route parameter, comparing its value with the subject of the
+// claims. Users whose roles grant every one of the permissions may act on
+// any resource.
+func RequireOwner(a *auth.Auth, param string, perms ...string) web.Middleware {
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			claims := auth.GetClaims(ctx)
+			if claims.Subject == "" {
+				return validate.NewRequestError(
+					errors.New("you are not authorized for that action, no claims"),
+					http.StatusForbidden,
+				)
+			}
+
+			if err := a.AuthorizeOwner(claims, web.Param(r, param), perms...); err != nil {
+				return validate.NewRequestError(
+					fmt.Errorf("you are not authorized for that action: %w", err),
+					http.StatusForbidden,
+				)
 			}
 
 			return handler(ctx, w, r)
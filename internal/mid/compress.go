@@ -0,0 +1,314 @@
+package mid
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ardanlabs/service/internal/platform/web"
+)
+
+// compressMinSize is the smallest body worth compressing. Smaller bodies
+// gain less than the cost of the encoding headers.
+const compressMinSize = 1024
+
+// Set of supported content encodings, in order of preference.
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+)
+
+// compressor is the part of gzip.Writer and zlib.Writer the middleware uses.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressorPools keeps the writers of each encoding for reuse, they hold
+// large buffers that are expensive to allocate per request.
+var compressorPools = map[string]*sync.Pool{
+	encodingGzip: {
+		New: func() any {
+			return gzip.NewWriter(io.Discard)
+		},
+	},
+	// The deflate content coding is the zlib format, not a raw deflate
+	// stream (RFC 9110 section 8.4.1.2).
+	encodingDeflate: {
+		New: func() any {
+			return zlib.NewWriter(io.Discard)
+		},
+	},
+}
+
+// incompressibleTypes lists the media types and type prefixes of content
+// that is already compressed.
+var incompressibleTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"font/woff",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/x-bzip2",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/zstd",
+}
+
+// Compress compresses response bodies with the encoding negotiated with the
+// Accept-Encoding header of the request. Bodies are held back until they
+// are large enough to be worth compressing, unless the handler flushes, and
+// content that is already compressed is sent as is. It must run outside of
+// Errors so error responses are compressed too. WebSocket upgrades are left
+// alone.
+func Compress(handler web.Handler) web.Handler {
+
+	h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		if r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			return handler(ctx, w, r)
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			return handler(ctx, w, r)
+		}
+
+		cw := compressWriter{
+			ResponseWriter: w,
+			encoding:       encoding,
+		}
+
+		err := handler(ctx, &cw, r)
+
+		// The handler is done, failing to write the rest of the body means
+		// the client went away and there is nobody left to tell.
+		cw.close()
+
+		return err
+	}
+
+	return h
+}
+
+// negotiateEncoding returns the preferred supported encoding the client
+// accepts, or an empty string when the body must not be compressed.
+func negotiateEncoding(accept string) string {
+	quality := make(map[string]float64)
+	wildcard := -1.0
+
+	for _, part := range strings.Split(accept, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(param, "=")
+			if strings.TrimSpace(name) != "q" {
+				continue
+			}
+			v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				q = 0
+				break
+			}
+			q = v
+		}
+
+		if coding == "*" {
+			wildcard = q
+			continue
+		}
+		quality[coding] = q
+	}
+
+	var best string
+	var bestQ float64
+	for _, encoding := range []string{encodingGzip, encodingDeflate} {
+		q, ok := quality[encoding]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+
+	return best
+}
+
+// =============================================================================
+
+// compressWriter holds back the start of the body until it knows whether the
+// response is worth compressing, then writes it through a pooled compressor
+// or as is.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+
+	status  int
+	buf     []byte
+	started bool
+	cw      compressor
+}
+
+// WriteHeader records the status until the body starts. Responses that
+// can't have a body are sent right away.
+func (w *compressWriter) WriteHeader(statusCode int) {
+	if w.started || w.status != 0 {
+		return
+	}
+
+	if statusCode < http.StatusOK {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+
+	w.status = statusCode
+	if !web.BodyAllowed(statusCode) {
+		w.start(false)
+	}
+}
+
+// Write holds back the body until it reaches the minimum size.
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.started {
+		w.buf = append(w.buf, data...)
+		if len(w.buf) < compressMinSize {
+			return len(data), nil
+		}
+
+		if err := w.start(w.compressible()); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+
+	if w.cw != nil {
+		return w.cw.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush sends what was written so far. A response that flushes is a stream
+// of unknown size, so it's compressed whatever its size so far.
+func (w *compressWriter) Flush() {
+	if !w.started {
+		if err := w.start(w.compressible()); err != nil {
+			return
+		}
+	}
+
+	if w.cw != nil {
+		if err := w.cw.Flush(); err != nil {
+			return
+		}
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// compressible reports whether the response should be compressed.
+func (w *compressWriter) compressible() bool {
+	if !web.BodyAllowed(w.status) {
+		return false
+	}
+
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	contentType := strings.ToLower(h.Get("Content-Type"))
+	for _, t := range incompressibleTypes {
+		if strings.HasPrefix(contentType, t) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// start writes the header and the body held back so far, compressing from
+// now on when asked to.
+func (w *compressWriter) start(compress bool) error {
+	w.started = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if compress {
+		h := w.Header()
+
+		// The content type can't be sniffed from the compressed body.
+		if h.Get("Content-Type") == "" {
+			h.Set("Content-Type", http.DetectContentType(w.buf))
+		}
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", w.encoding)
+
+		w.cw = compressorPools[w.encoding].Get().(compressor)
+		w.cw.Reset(w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if w.cw != nil {
+		_, err = w.cw.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// close sends a body that never reached the minimum size as is, or ends
+// the compressed body and returns the compressor to its pool.
+func (w *compressWriter) close() error {
+	if !w.started {
+		if w.status == 0 && len(w.buf) == 0 {
+			return nil
+		}
+		if err := w.start(false); err != nil {
+			return err
+		}
+	}
+
+	if w.cw == nil {
+		return nil
+	}
+
+	err := w.cw.Close()
+
+	// Drop the reference to the response before pooling the compressor.
+	w.cw.Reset(io.Discard)
+	compressorPools[w.encoding].Put(w.cw)
+	w.cw = nil
+
+	return err
+}
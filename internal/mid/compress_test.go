@@ -0,0 +1,286 @@
+package mid_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/internal/mid"
+	"github.com/ardanlabs/service/internal/platform/web"
+)
+
+func Test_CompressNegotiation(t *testing.T) {
+	t.Log("Given the need to compress responses in an encoding the client accepts.")
+
+	body := strings.Repeat("compressible ", 200)
+
+	app := web.New(nil, nil, mid.Compress)
+	app.Handle(http.MethodGet, "", "/text", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "text/plain")
+		_, err := io.WriteString(w, body)
+		return err
+	})
+
+	tt := []struct {
+		accept   string
+		encoding string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"GZIP", "gzip"},
+		{"deflate", "deflate"},
+		{"gzip, deflate", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"gzip;q=0, deflate;q=0.1", "deflate"},
+		{"*", "gzip"},
+		{"gzip;q=0, *", "deflate"},
+		{"*;q=0", ""},
+		{"gzip;q=0, deflate;q=0", ""},
+		{"gzip;q=bad", ""},
+		{"br", ""},
+	}
+
+	for testID, tst := range tt {
+		t.Logf("\tTest %d:\tWhen the client accepts %q.", testID, tst.accept)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/text", nil)
+			if tst.accept != "" {
+				r.Header.Set("Accept-Encoding", tst.accept)
+			}
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if got := w.Header().Get("Content-Encoding"); got != tst.encoding {
+				t.Fatalf("\t%s\tTest %d:\tShould respond with encoding %q : got %q.", failed, testID, tst.encoding, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould respond with encoding %q.", success, testID, tst.encoding)
+
+			if got := decode(t, tst.encoding, w.Body); got != body {
+				t.Fatalf("\t%s\tTest %d:\tShould decode to the body : got %d bytes.", failed, testID, len(got))
+			}
+			t.Logf("\t%s\tTest %d:\tShould decode to the body.", success, testID)
+
+			if !strings.Contains(w.Header().Get("Vary"), "Accept-Encoding") {
+				t.Fatalf("\t%s\tTest %d:\tShould vary on Accept-Encoding : got %q.", failed, testID, w.Header().Get("Vary"))
+			}
+			t.Logf("\t%s\tTest %d:\tShould vary on Accept-Encoding.", success, testID)
+		}
+	}
+}
+
+func Test_CompressContent(t *testing.T) {
+	t.Log("Given the need to only compress responses worth compressing.")
+
+	large := strings.Repeat("x", 4096)
+
+	tt := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		compressed  bool
+	}{
+		{"a large text body", "text/plain", large, http.StatusOK, true},
+		{"a large error body", "application/json", large, http.StatusInternalServerError, true},
+		{"a small body", "text/plain", "small", http.StatusOK, false},
+		{"an empty body", "text/plain", "", http.StatusOK, false},
+		{"an image", "image/png", large, http.StatusOK, false},
+		{"a video", "video/mp4", large, http.StatusOK, false},
+		{"a zip archive", "application/zip", large, http.StatusOK, false},
+		{"a gzip archive", "application/gzip", large, http.StatusOK, false},
+		{"no content", "", "", http.StatusNoContent, false},
+		{"not modified", "", "", http.StatusNotModified, false},
+	}
+
+	for testID, tst := range tt {
+		t.Logf("\tTest %d:\tWhen sending %s.", testID, tst.name)
+		{
+			app := web.New(nil, nil, mid.Compress)
+			app.Handle(http.MethodGet, "", "/content", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				if tst.status == http.StatusNoContent || tst.status == http.StatusNotModified {
+					return web.Respond(ctx, w, nil, tst.status)
+				}
+
+				if tst.contentType != "" {
+					w.Header().Set("Content-Type", tst.contentType)
+				}
+				w.WriteHeader(tst.status)
+				_, err := io.WriteString(w, tst.body)
+				return err
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/content", nil)
+			r.Header.Set("Accept-Encoding", "gzip")
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if w.Code != tst.status {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the status : got %d.", failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the status.", success, testID)
+
+			encoding := w.Header().Get("Content-Encoding")
+			if (encoding == "gzip") != tst.compressed {
+				t.Fatalf("\t%s\tTest %d:\tShould compress the body %v : got encoding %q.", failed, testID, tst.compressed, encoding)
+			}
+			t.Logf("\t%s\tTest %d:\tShould compress the body %v.", success, testID, tst.compressed)
+
+			if got := decode(t, encoding, w.Body); got != tst.body {
+				t.Fatalf("\t%s\tTest %d:\tShould send the body : got %d bytes.", failed, testID, len(got))
+			}
+			t.Logf("\t%s\tTest %d:\tShould send the body.", success, testID)
+		}
+	}
+}
+
+func Test_CompressBypass(t *testing.T) {
+	t.Log("Given the need to leave some requests alone.")
+
+	large := strings.Repeat("x", 4096)
+
+	tt := []struct {
+		name   string
+		method string
+		header string
+		value  string
+	}{
+		{"a WebSocket upgrade", http.MethodGet, "Upgrade", "websocket"},
+		{"a HEAD request", http.MethodHead, "", ""},
+	}
+
+	for testID, tst := range tt {
+		t.Logf("\tTest %d:\tWhen handling %s.", testID, tst.name)
+		{
+			var wrapped bool
+			app := web.New(nil, nil, mid.Compress)
+			app.Handle(tst.method, "", "/bypass", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				_, wrapped = w.(*httptest.ResponseRecorder)
+				wrapped = !wrapped
+				_, err := io.WriteString(w, large)
+				return err
+			})
+
+			r := httptest.NewRequest(tst.method, "/bypass", nil)
+			r.Header.Set("Accept-Encoding", "gzip")
+			if tst.header != "" {
+				r.Header.Set(tst.header, tst.value)
+			}
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if wrapped {
+				t.Fatalf("\t%s\tTest %d:\tShould hand the handler the connection's writer.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould hand the handler the connection's writer.", success, testID)
+
+			if encoding := w.Header().Get("Content-Encoding"); encoding != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould not compress : got %q.", failed, testID, encoding)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not compress.", success, testID)
+		}
+	}
+}
+
+func Test_CompressStream(t *testing.T) {
+	t.Log("Given the need to compress a stream without holding it back.")
+
+	next := make(chan struct{})
+
+	app := web.New(nil, nil, mid.Compress)
+	app.Handle(http.MethodGet, "", "/events", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		events := make(chan web.Event)
+		go func() {
+			defer close(events)
+			events <- web.Event{ID: "1", Data: "first"}
+			<-next
+			events <- web.Event{ID: "2", Data: "second"}
+		}()
+
+		return web.StreamEvents(ctx, w, events, web.StreamConfig{Heartbeat: time.Hour})
+	})
+
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen events are sent one at a time.", testID)
+		{
+			req, _ := http.NewRequest(http.MethodGet, srv.URL+"/events", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+
+			// The transport would otherwise decompress the body itself.
+			client := http.Client{Transport: &http.Transport{DisableCompression: true}}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to connect : %v.", failed, testID, err)
+			}
+			defer resp.Body.Close()
+
+			if encoding := resp.Header.Get("Content-Encoding"); encoding != "gzip" {
+				t.Fatalf("\t%s\tTest %d:\tShould compress the stream : got %q.", failed, testID, encoding)
+			}
+			t.Logf("\t%s\tTest %d:\tShould compress the stream.", success, testID)
+
+			zr, err := gzip.NewReader(resp.Body)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to read the compressed stream : %v.", failed, testID, err)
+			}
+			sc := bufio.NewScanner(zr)
+
+			// The second event is held back until the first one is read, so
+			// reading it proves the first event was flushed on its own.
+			if line := readEventData(sc); line != "data: first" {
+				t.Fatalf("\t%s\tTest %d:\tShould flush each event : got %q.", failed, testID, line)
+			}
+			close(next)
+			if line := readEventData(sc); line != "data: second" {
+				t.Fatalf("\t%s\tTest %d:\tShould flush each event : got %q.", failed, testID, line)
+			}
+			t.Logf("\t%s\tTest %d:\tShould flush each event.", success, testID)
+		}
+	}
+}
+
+// readEventData returns the next data line of an event stream.
+func readEventData(sc *bufio.Scanner) string {
+	for sc.Scan() {
+		if strings.HasPrefix(sc.Text(), "data:") {
+			return sc.Text()
+		}
+	}
+	return sc.Err().Error()
+}
+
+// decode returns the body in the content encoding as plain text.
+func decode(t *testing.T, encoding string, body io.Reader) string {
+	var r io.Reader
+	var err error
+
+	switch encoding {
+	case "":
+		r = body
+	case "gzip":
+		r, err = gzip.NewReader(body)
+	case "deflate":
+		r, err = zlib.NewReader(body)
+	default:
+		t.Fatalf("\t%s\tShould know the encoding %q.", failed, encoding)
+	}
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to read the %s body : %v.", failed, encoding, err)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to read the %s body : %v.", failed, encoding, err)
+	}
+	return string(data)
+}
@@ -2,56 +2,77 @@ package mid
 
 import (
 	"context"
+	"net/http"
+
 	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/internal/platform/logger"
 	"github.com/ardanlabs/service/internal/platform/web"
-	"log"
-	"net/http"
 )
 
-// ErrorHandler for catching and responding errors.
-func Errors(handler web.Handler) web.Handler {
+// Errors handles errors coming out of the call chain. It detects normal
+// application errors which are used to respond to the client in a uniform way.
+// Every error is logged along with the trace id of the request, at the error
+// level only when the status is 500 or above.
+// The error response is encoded in the format negotiated with the client.
+func Errors(log *logger.Logger) web.Middleware {
+
+	m := func(handler web.Handler) web.Handler {
+
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-			v := web.GetValues(ctx)
 
 			// Run the next handler and catch any propagated error
 			if err := handler(ctx, w, r); err != nil {
-				log.Println("ERROR", "trace_id", v.TraceID, "ERROR", err)
-
-			var er validate.ErrorResponse
-			var status int
-			switch {
-			case validate.IsFieldErrors(err):
-				fieldErrors := validate.GetFieldErrors(err)
-				er = validate.ErrorResponse{
-					Error:  "data validation error",
-					Fields: fieldErrors.Fields(),
+				var er validate.ErrorResponse
+				var status int
+				switch {
+				case validate.IsFieldErrors(err):
+					fieldErrors := validate.GetFieldErrors(err)
+					er = validate.ErrorResponse{
+						Error:  "data validation error",
+						Fields: fieldErrors.Fields(),
+					}
+					status = http.StatusBadRequest
+
+				case validate.IsRequestError(err):
+					reqErr := validate.GetRequestError(err)
+					er = validate.ErrorResponse{
+						Error: reqErr.Error(),
+					}
+					status = reqErr.Status
+
+				default:
+					er = validate.ErrorResponse{
+						Error: http.StatusText(http.StatusInternalServerError),
+					}
+					status = http.StatusInternalServerError
 				}
-				status = http.StatusBadRequest
 
-			case validate.IsRequestError(err):
-				reqErr := validate.GetRequestError(err)
-				er = validate.ErrorResponse{
-					Error: reqErr.Error(),
+				// Client mistakes are part of normal operation, only
+				// failures of the service itself are logged as errors.
+				if status < http.StatusInternalServerError {
+					log.Info(ctx, "request error", "method", r.Method,
+						"path", r.URL.Path, "status", status, "error", err)
+				} else {
+					log.Error(ctx, "request error", "method", r.Method,
+						"path", r.URL.Path, "status", status, "error", err)
 				}
-				status = reqErr.Status
-			default:
-				er = validate.ErrorResponse{
-					Error: http.StatusText(http.StatusInternalServerError),
+
+				if err := web.Respond(ctx, w, er, status); err != nil {
+					return err
 				}
-				status = http.StatusInternalServerError
-			}
 
-			if err:= web.Respond(ctx, w, er, status); err!=nil {
-				return err
+				// If we receive the shutdown err we need to return it
+				// back to the base handler to shut down the service.
+				if ok := web.IsShutdown(err); ok {
+					return err
+				}
 			}
 
-			if ok := web.IsShutdown(err); ok {
-				return err
-			}
+			return nil
 		}
-		return nil
-		}
-	return h
-	}
 
+		return h
+	}
 
+	return m
+}
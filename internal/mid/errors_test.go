@@ -0,0 +1,102 @@
+package mid_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/internal/mid"
+	"github.com/ardanlabs/service/internal/platform/logger"
+	"github.com/ardanlabs/service/internal/platform/web"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_Errors(t *testing.T) {
+	t.Log("Given the need to report the errors of a request.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a handler returns a request error.", testID)
+		{
+			var buf bytes.Buffer
+			log := logger.New(&buf, "TEST", logger.LevelInfo, web.GetTraceID)
+
+			app := web.New(nil, nil, mid.Errors(log))
+			app.Handle(http.MethodGet, "", "/users/:id", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				return validate.NewRequestError(errors.New("user not found"), http.StatusNotFound)
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould respond with the status of the error : got %d.", failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould respond with the status of the error.", success, testID)
+
+			var entry map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould log a JSON document : %v.", failed, testID, err)
+			}
+
+			exp := map[string]any{
+				"msg":      "request error",
+				"level":    "INFO",
+				"trace_id": w.Header().Get(web.TraceIDHeader),
+				"status":   float64(http.StatusNotFound),
+				"path":     "/users/42",
+				"error":    "user not found",
+			}
+			for key, value := range exp {
+				if entry[key] != value {
+					t.Fatalf("\t%s\tTest %d:\tShould log %q as %v : got %v.", failed, testID, key, value, entry[key])
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould log the error with the trace id and status.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen a handler fails.", testID)
+		{
+			var buf bytes.Buffer
+			log := logger.New(&buf, "TEST", logger.LevelInfo, web.GetTraceID)
+
+			app := web.New(nil, nil, mid.Errors(log))
+			app.Handle(http.MethodGet, "", "/users/:id", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				return errors.New("database is down")
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusInternalServerError {
+				t.Fatalf("\t%s\tTest %d:\tShould respond with a server error : got %d.", failed, testID, w.Code)
+			}
+			if bytes.Contains(w.Body.Bytes(), []byte("database is down")) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT send the error to the client : got %s.", failed, testID, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould respond with a server error.", success, testID)
+
+			var entry map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould log a JSON document : %v.", failed, testID, err)
+			}
+
+			if entry["level"] != "ERROR" || entry["error"] != "database is down" {
+				t.Fatalf("\t%s\tTest %d:\tShould log the error at the error level : got %v.", failed, testID, entry)
+			}
+			t.Logf("\t%s\tTest %d:\tShould log the error at the error level.", success, testID)
+		}
+	}
+}
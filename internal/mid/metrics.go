@@ -2,29 +2,35 @@ package mid
 
 import (
 	"context"
-	"github.com/ardanlabs/service/internal/platform/web"
 	"net/http"
+	"time"
 
 	"github.com/ardanlabs/service/internal/platform/metrics"
+	"github.com/ardanlabs/service/internal/platform/web"
 )
 
-// Metrics updates program counters.
+// Metrics updates program counters and records the method, route, status
+// and latency of each request. It must run outside of Errors so the status
+// of the response is known.
 func Metrics(handler web.Handler) web.Handler {
 
-		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-			ctx = metrics.Set(ctx)
-
-			err := handler(ctx, w, r)
+	h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		ctx = metrics.Set(ctx)
+		v := web.GetValues(ctx)
 
-			metrics.AddRequests(ctx)
-			metrics.AddGoroutines(ctx)
+		err := handler(ctx, w, r)
 
-			if err != nil {
-				metrics.AddErrors(ctx)
-			}
+		metrics.AddRequests(ctx)
+		metrics.AddGoroutines(ctx)
 
-			return err
+		if err != nil || v.StatusCode >= http.StatusBadRequest {
+			metrics.AddErrors(ctx)
 		}
 
-		return h
+		metrics.ObserveRequest(ctx, r.Method, v.Route, v.StatusCode, time.Since(v.Now))
+
+		return err
 	}
+
+	return h
+}
@@ -0,0 +1,559 @@
+// Package conf fills a configuration struct from default values, environment
+// variables and command line flags, in that order of precedence.
+//
+// Each field is named after its path in the struct. For the prefix "SALES"
+// the field Web.ReadTimeout is read from the environment variable
+// SALES_WEB_READ_TIMEOUT and the flag --web-read-timeout. Fields are
+// configured with a `conf` struct tag:
+//
+//	default:<value>  value used when nothing else is provided
+//	env:<name>       override the environment variable name (without prefix)
+//	flag:<name>      override the flag name
+//	help:<text>      description shown in the help output
+//	required         parsing fails when no value is provided
+//	noprint          the field is left out of String
+//	mask             the field's value is hidden by String
+//
+// Options are separated by commas. Only a default value may contain a comma,
+// and the text following it must not look like another option. Slice values
+// are separated by semicolons.
+package conf
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrHelpWanted is returned by Parse when the --help or --version flag is
+// provided. The string returned by Parse holds the text to display.
+var ErrHelpWanted = errors.New("help wanted")
+
+// Version provides the version information displayed by --version. Embed it
+// in the configuration struct to enable the flag.
+type Version struct {
+	Build string
+	Desc  string
+}
+
+// Parse fills the struct pointed to by cfg. The prefix is used to build the
+// environment variable names. When help or version information is requested
+// the text is returned along with ErrHelpWanted.
+func Parse(prefix string, cfg any) (string, error) {
+	return parse(os.Args[1:], os.LookupEnv, prefix, cfg)
+}
+
+// String returns a stable, human readable list of the configuration values
+// that can be written to the logs. Fields tagged noprint are left out and
+// fields tagged mask have their value hidden.
+func String(cfg any) (string, error) {
+	fields, err := extractFields("", cfg)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, fld := range fields {
+		if fld.options.noprint {
+			continue
+		}
+
+		value := fmt.Sprint(fld.value.Interface())
+		if fld.options.mask && value != "" {
+			value = "xxxxxx"
+		}
+
+		fmt.Fprintf(&b, "\n--%s=%s", fld.flagKey, value)
+	}
+
+	return b.String(), nil
+}
+
+// =============================================================================
+
+// parse performs the work of Parse with the arguments and environment
+// provided so it can be tested.
+func parse(args []string, lookupEnv func(string) (string, bool), prefix string, cfg any) (string, error) {
+	fields, err := extractFields(prefix, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	flags, err := parseFlags(args)
+	if err != nil {
+		return "", err
+	}
+
+	if _, exists := flags["help"]; exists {
+		return usage(fields), ErrHelpWanted
+	}
+	if _, exists := flags["version"]; exists {
+		return versionString(cfg), ErrHelpWanted
+	}
+
+	for _, fld := range fields {
+		value, provided := fld.options.defaultVal, fld.options.hasDefault
+
+		if v, exists := lookupEnv(fld.envKey); exists {
+			value, provided = v, true
+		}
+
+		if v, exists := flags[fld.flagKey]; exists {
+			value, provided = v, true
+			delete(flags, fld.flagKey)
+		}
+
+		if !provided {
+			if fld.options.required {
+				return "", fmt.Errorf("required field %s is missing value", fld.name)
+			}
+			continue
+		}
+
+		if err := setValue(fld.value, value); err != nil {
+			return "", fmt.Errorf("parsing field %s value %q: %w", fld.name, value, err)
+		}
+	}
+
+	if len(flags) > 0 {
+		names := make([]string, 0, len(flags))
+		for name := range flags {
+			names = append(names, "--"+name)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("unknown flags %v", names)
+	}
+
+	return "", nil
+}
+
+// parseFlags converts the command line arguments into a map of flag name to
+// value. Flags take the form --name=value, --name value or, for booleans,
+// just --name.
+func parseFlags(args []string) (map[string]string, error) {
+	flags := make(map[string]string)
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch arg {
+		case "-h", "--help":
+			flags["help"] = ""
+			continue
+		case "-v", "--version":
+			flags["version"] = ""
+			continue
+		}
+
+		if !strings.HasPrefix(arg, "--") || len(arg) == 2 {
+			return nil, fmt.Errorf("unexpected argument %q", arg)
+		}
+
+		name, value, hasValue := strings.Cut(arg[2:], "=")
+		if !hasValue {
+			value = "true"
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				value = args[i+1]
+				i++
+			}
+		}
+
+		flags[name] = value
+	}
+
+	return flags, nil
+}
+
+// =============================================================================
+
+// field represents a single configurable value in the struct.
+type field struct {
+	name    string
+	envKey  string
+	flagKey string
+	value   reflect.Value
+	options fieldOptions
+}
+
+// fieldOptions holds the options declared in a conf tag.
+type fieldOptions struct {
+	defaultVal string
+	hasDefault bool
+	env        string
+	flag       string
+	help       string
+	required   bool
+	noprint    bool
+	mask       bool
+}
+
+// extractFields walks the struct pointed to by cfg and returns every field
+// that can be configured.
+func extractFields(prefix string, cfg any) ([]field, error) {
+	rv := reflect.ValueOf(cfg)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("conf: expected a pointer to a struct")
+	}
+
+	return walk(prefix, nil, nil, rv.Elem())
+}
+
+// walk collects the fields of the struct, descending into nested structs.
+// The path holds the words used to build env and flag names and goPath holds
+// the Go field names used in error messages.
+func walk(prefix string, path []string, goPath []string, rv reflect.Value) ([]field, error) {
+	var fields []field
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() || sf.Type == reflect.TypeOf(Version{}) {
+			continue
+		}
+
+		tag := sf.Tag.Get("conf")
+		if tag == "-" {
+			continue
+		}
+
+		opts, err := parseTag(tag)
+		if err != nil {
+			return nil, fmt.Errorf("conf: field %s: %w", sf.Name, err)
+		}
+
+		fv := rv.Field(i)
+		fieldPath := append(append([]string(nil), path...), camelSplit(sf.Name)...)
+		fieldGoPath := append(append([]string(nil), goPath...), sf.Name)
+
+		if isNested(fv) {
+			if sf.Anonymous {
+				fieldPath, fieldGoPath = path, goPath
+			}
+			nested, err := walk(prefix, fieldPath, fieldGoPath, fv)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, nested...)
+			continue
+		}
+
+		envParts := fieldPath
+		if opts.env != "" {
+			envParts = []string{opts.env}
+		}
+		if prefix != "" {
+			envParts = append([]string{prefix}, envParts...)
+		}
+
+		flagKey := strings.ToLower(strings.Join(fieldPath, "-"))
+		if opts.flag != "" {
+			flagKey = opts.flag
+		}
+
+		fields = append(fields, field{
+			name:    strings.Join(fieldGoPath, "."),
+			envKey:  strings.ToUpper(strings.Join(envParts, "_")),
+			flagKey: flagKey,
+			value:   fv,
+			options: opts,
+		})
+	}
+
+	return fields, nil
+}
+
+// isNested reports if the value is a struct whose fields should be walked
+// rather than a value that is set directly.
+func isNested(fv reflect.Value) bool {
+	if fv.Kind() != reflect.Struct {
+		return false
+	}
+	if fv.Type() == reflect.TypeOf(time.Time{}) {
+		return false
+	}
+	if fv.CanAddr() {
+		if _, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTag parses the options declared in a conf tag.
+func parseTag(tag string) (fieldOptions, error) {
+	var opts fieldOptions
+	if tag == "" {
+		return opts, nil
+	}
+
+	parts := strings.Split(tag, ",")
+	for i := 0; i < len(parts); i++ {
+		name, value, _ := strings.Cut(parts[i], ":")
+
+		switch name {
+		case "default":
+			// Parts that aren't options belong to the default value.
+			for i+1 < len(parts) && !isOption(parts[i+1]) {
+				value += "," + parts[i+1]
+				i++
+			}
+			opts.defaultVal = value
+			opts.hasDefault = true
+		case "env":
+			opts.env = value
+		case "flag":
+			opts.flag = value
+		case "help":
+			// Parts that aren't options belong to the help text.
+			for i+1 < len(parts) && !isOption(parts[i+1]) {
+				value += "," + parts[i+1]
+				i++
+			}
+			opts.help = value
+		case "required":
+			opts.required = true
+		case "noprint":
+			opts.noprint = true
+		case "mask":
+			opts.mask = true
+		default:
+			return fieldOptions{}, fmt.Errorf("unknown tag option %q", name)
+		}
+	}
+
+	return opts, nil
+}
+
+// isOption reports if the text from a tag starts a known option.
+func isOption(part string) bool {
+	name, _, _ := strings.Cut(part, ":")
+
+	switch name {
+	case "default", "env", "flag", "help", "required", "noprint", "mask":
+		return true
+	}
+	return false
+}
+
+// initialisms are the acronyms camelSplit recognizes when several of them
+// are written next to each other, like JWKSURL.
+var initialisms = []string{
+	"API", "CPU", "DB", "DNS", "HTTP", "HTTPS", "ID", "IP", "JSON", "JWKS",
+	"JWT", "KID", "OTLP", "RPC", "SQL", "SSH", "TCP", "TLS", "TTL", "UDP",
+	"UI", "URI", "URL", "UUID", "XML",
+}
+
+// camelSplit splits a Go field name into words. Runs of upper case letters
+// are treated as an acronym: APIHost becomes API and Host. A run made of
+// known initialisms is split into them: JWKSURL becomes JWKS and URL.
+func camelSplit(s string) []string {
+	runes := []rune(s)
+
+	var words []string
+	add := func(word string) {
+		if parts := splitInitialisms(word); parts != nil {
+			words = append(words, parts...)
+			return
+		}
+		words = append(words, word)
+	}
+
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+
+		switch {
+		case unicode.IsLower(prev) && unicode.IsUpper(cur):
+			add(string(runes[start:i]))
+			start = i
+		case unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(runes) && unicode.IsLower(runes[i+1]):
+			add(string(runes[start:i]))
+			start = i
+		}
+	}
+	add(string(runes[start:]))
+
+	return words
+}
+
+// splitInitialisms splits an upper case word made of two or more known
+// initialisms. It returns nil for any other word, including a single
+// initialism.
+func splitInitialisms(word string) []string {
+	if word != strings.ToUpper(word) {
+		return nil
+	}
+	for _, known := range initialisms {
+		if word == known {
+			return nil
+		}
+	}
+
+	var split func(rest string) []string
+	split = func(rest string) []string {
+		if rest == "" {
+			return []string{}
+		}
+		for _, known := range initialisms {
+			if !strings.HasPrefix(rest, known) {
+				continue
+			}
+			if tail := split(rest[len(known):]); tail != nil {
+				return append([]string{known}, tail...)
+			}
+		}
+		return nil
+	}
+
+	if parts := split(word); len(parts) > 1 {
+		return parts
+	}
+	return nil
+}
+
+// setValue converts the string into the type of the field and sets it.
+func setValue(fv reflect.Value, value string) error {
+	if fv.CanAddr() {
+		if tu, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return tu.UnmarshalText([]byte(value))
+		}
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if fv.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			fv.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(value, 0, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 0, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+
+	case reflect.Slice:
+		var items []string
+		if value != "" {
+			items = strings.Split(value, ";")
+		}
+		slice := reflect.MakeSlice(fv.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setValue(slice.Index(i), strings.TrimSpace(item)); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// usage builds the help text for the set of fields.
+func usage(fields []field) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Usage: %s [options]\n\nOPTIONS\n", filepath.Base(os.Args[0]))
+
+	for _, fld := range fields {
+		fmt.Fprintf(&b, "  --%s/$%s  <%s>", fld.flagKey, fld.envKey, typeName(fld.value))
+		if fld.options.hasDefault {
+			def := fld.options.defaultVal
+			if fld.options.mask {
+				def = "xxxxxx"
+			}
+			fmt.Fprintf(&b, "  (default: %s)", def)
+		}
+		if fld.options.required {
+			b.WriteString("  (required)")
+		}
+		b.WriteString("\n")
+		if fld.options.help != "" {
+			fmt.Fprintf(&b, "      %s\n", fld.options.help)
+		}
+	}
+
+	b.WriteString("  --help/-h\n      display this help message\n")
+	b.WriteString("  --version/-v\n      display version information\n")
+
+	return b.String()
+}
+
+// typeName describes the type of value a field expects.
+func typeName(fv reflect.Value) string {
+	if fv.Type() == reflect.TypeOf(time.Duration(0)) {
+		return "duration"
+	}
+
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "int"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.Slice:
+		return "value;value"
+	}
+
+	return fv.Kind().String()
+}
+
+// versionString builds the version text from the embedded Version, if any.
+func versionString(cfg any) string {
+	rv := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		if !rv.Type().Field(i).IsExported() {
+			continue
+		}
+		if v, ok := rv.Field(i).Interface().(Version); ok {
+			var b strings.Builder
+			fmt.Fprintf(&b, "Version: %s", v.Build)
+			if v.Desc != "" {
+				fmt.Fprintf(&b, "\n%s", v.Desc)
+			}
+			return b.String()
+		}
+	}
+
+	return "Version: unknown"
+}
@@ -0,0 +1,150 @@
+package conf_test
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/internal/platform/conf"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+type config struct {
+	conf.Version
+	Web struct {
+		ReadTimeout time.Duration `conf:"default:5s"`
+		APIHost     string        `conf:"default:0.0.0.0:3000,help:address the api listens on"`
+	}
+	Auth struct {
+		ActiveKID     string        `conf:"required"`
+		Audiences     []string      `conf:"default:sales;inventory"`
+		RemoteJWKSURL string        `conf:"help:keys of the identity provider, fetched on demand"`
+		RemoteJWKSTTL time.Duration `conf:"default:1h,help:how long the keys are cached, zero disables the cache"`
+	}
+	DB struct {
+		Password string `conf:"default:postgres,mask"`
+		Internal string `conf:"default:secret,noprint"`
+	}
+	Debug bool
+}
+
+func Test_Parse(t *testing.T) {
+	t.Log("Given the need to parse configuration.")
+
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling defaults, env and flags.", testID)
+		{
+			t.Setenv("TEST_WEB_READ_TIMEOUT", "10s")
+			t.Setenv("TEST_WEB_API_HOST", "0.0.0.0:5000")
+			t.Setenv("TEST_AUTH_ACTIVE_KID", "abc")
+			setArgs(t, "--web-api-host=0.0.0.0:6000", "--debug")
+
+			var cfg config
+			if _, err := conf.Parse("TEST", &cfg); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to parse the config : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to parse the config.", success, testID)
+
+			if cfg.Web.ReadTimeout != 10*time.Second || cfg.Auth.ActiveKID != "abc" {
+				t.Fatalf("\t%s\tTest %d:\tShould take values from the env : %+v.", failed, testID, cfg)
+			}
+			t.Logf("\t%s\tTest %d:\tShould take values from the env.", success, testID)
+
+			if cfg.Web.APIHost != "0.0.0.0:6000" || !cfg.Debug {
+				t.Fatalf("\t%s\tTest %d:\tShould take flags over the env : %+v.", failed, testID, cfg)
+			}
+			t.Logf("\t%s\tTest %d:\tShould take flags over the env.", success, testID)
+
+			if len(cfg.Auth.Audiences) != 2 || cfg.DB.Password != "postgres" {
+				t.Fatalf("\t%s\tTest %d:\tShould take defaults when nothing is provided : %+v.", failed, testID, cfg)
+			}
+			t.Logf("\t%s\tTest %d:\tShould take defaults when nothing is provided.", success, testID)
+
+			out, err := conf.String(&cfg)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to print the config : %v.", failed, testID, err)
+			}
+
+			if !strings.Contains(out, "--db-password=xxxxxx") || strings.Contains(out, "postgres") || strings.Contains(out, "secret") {
+				t.Fatalf("\t%s\tTest %d:\tShould mask and hide secrets : %s.", failed, testID, out)
+			}
+			t.Logf("\t%s\tTest %d:\tShould mask and hide secrets.", success, testID)
+		}
+	}
+
+	{
+		testID := 1
+		t.Logf("\tTest %d:\tWhen handling bad input.", testID)
+		{
+			setArgs(t)
+			var cfg config
+			if _, err := conf.Parse("BAD", &cfg); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould fail when a required field is missing.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould fail when a required field is missing.", success, testID)
+
+			t.Setenv("BAD_AUTH_ACTIVE_KID", "abc")
+			t.Setenv("BAD_WEB_READ_TIMEOUT", "soon")
+			if _, err := conf.Parse("BAD", &cfg); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould fail when a value can't be parsed.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould fail when a value can't be parsed.", success, testID)
+
+			t.Setenv("BAD_WEB_READ_TIMEOUT", "1s")
+			setArgs(t, "--web-write-timeout=1s")
+			if _, err := conf.Parse("BAD", &cfg); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould fail on an unknown flag.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould fail on an unknown flag.", success, testID)
+		}
+	}
+
+	{
+		testID := 2
+		t.Logf("\tTest %d:\tWhen asking for help and version information.", testID)
+		{
+			setArgs(t, "--help")
+			var cfg config
+			help, err := conf.Parse("TEST", &cfg)
+			if !errors.Is(err, conf.ErrHelpWanted) || !strings.Contains(help, "--web-api-host/$TEST_WEB_API_HOST") {
+				t.Fatalf("\t%s\tTest %d:\tShould get the help text : %v : %s.", failed, testID, err, help)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the help text.", success, testID)
+
+			for _, exp := range []string{
+				"--auth-remote-jwks-url/$TEST_AUTH_REMOTE_JWKS_URL",
+				"--auth-remote-jwks-ttl/$TEST_AUTH_REMOTE_JWKS_TTL",
+				"--auth-active-kid/$TEST_AUTH_ACTIVE_KID",
+				"keys of the identity provider, fetched on demand",
+				"how long the keys are cached, zero disables the cache",
+			} {
+				if !strings.Contains(help, exp) {
+					t.Fatalf("\t%s\tTest %d:\tShould split acronyms and keep commas in help : missing %q : %s.", failed, testID, exp, help)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould split acronyms and keep commas in help.", success, testID)
+
+			setArgs(t, "--version")
+			cfg.Version = conf.Version{Build: "v1.2.3"}
+			version, err := conf.Parse("TEST", &cfg)
+			if !errors.Is(err, conf.ErrHelpWanted) || !strings.Contains(version, "v1.2.3") {
+				t.Fatalf("\t%s\tTest %d:\tShould get the version : %v : %s.", failed, testID, err, version)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the version.", success, testID)
+		}
+	}
+}
+
+// setArgs replaces the command line arguments for the life of the test.
+func setArgs(t *testing.T, args ...string) {
+	orig := os.Args
+	os.Args = append([]string{"sales-api"}, args...)
+	t.Cleanup(func() { os.Args = orig })
+}
@@ -0,0 +1,212 @@
+// Package logger provides a structured, leveled logger that writes one JSON
+// document per line so log pipelines can parse the output.
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level represents the severity of a log entry.
+type Level int
+
+// Set of levels that are supported, in increasing order of severity.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String implements the fmt.Stringer interface.
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LEVEL(%d)", int(l))
+}
+
+// ParseLevel converts a level name like "info" into a Level.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(s) {
+	case "DEBUG":
+		return LevelDebug, nil
+	case "INFO":
+		return LevelInfo, nil
+	case "WARN", "WARNING":
+		return LevelWarn, nil
+	case "ERROR":
+		return LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+// emptyTraceID is the all zero trace id reported for a context that isn't
+// part of a trace. Those entries are written without a trace_id.
+const emptyTraceID = "00000000000000000000000000000000"
+
+// TraceIDFn returns the trace id carried by the context, if any. It keeps
+// the logger from depending on the package that puts the id there.
+type TraceIDFn func(ctx context.Context) string
+
+// =============================================================================
+
+// output serializes writes from a logger and every logger derived from it.
+type output struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Logger writes structured log entries. It is safe for concurrent use.
+type Logger struct {
+	out       *output
+	level     Level
+	service   string
+	traceIDFn TraceIDFn
+	fields    []any
+}
+
+// New constructs a Logger that writes entries at or above the specified
+// level to w. Every entry carries the service name, and the trace id the
+// function finds in the context. A nil function writes no trace ids.
+func New(w io.Writer, service string, level Level, traceIDFn TraceIDFn) *Logger {
+	return &Logger{
+		out:       &output{w: w},
+		level:     level,
+		service:   service,
+		traceIDFn: traceIDFn,
+	}
+}
+
+// With returns a Logger that adds the key/value pairs to every entry.
+func (l *Logger) With(args ...any) *Logger {
+	fields := make([]any, 0, len(l.fields)+len(args))
+	fields = append(fields, l.fields...)
+	fields = append(fields, args...)
+
+	return &Logger{
+		out:       l.out,
+		level:     l.level,
+		service:   l.service,
+		traceIDFn: l.traceIDFn,
+		fields:    fields,
+	}
+}
+
+// Enabled reports if entries at the specified level will be written.
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+// Debug writes an entry at the debug level.
+func (l *Logger) Debug(ctx context.Context, msg string, args ...any) {
+	l.write(ctx, LevelDebug, msg, args)
+}
+
+// Info writes an entry at the info level.
+func (l *Logger) Info(ctx context.Context, msg string, args ...any) {
+	l.write(ctx, LevelInfo, msg, args)
+}
+
+// Warn writes an entry at the warn level.
+func (l *Logger) Warn(ctx context.Context, msg string, args ...any) {
+	l.write(ctx, LevelWarn, msg, args)
+}
+
+// Error writes an entry at the error level.
+func (l *Logger) Error(ctx context.Context, msg string, args ...any) {
+	l.write(ctx, LevelError, msg, args)
+}
+
+// write formats the entry and writes it as a single line. The args are
+// treated as alternating keys and values.
+func (l *Logger) write(ctx context.Context, level Level, msg string, args []any) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	var b bytes.Buffer
+	b.WriteByte('{')
+	field(&b, "time", time.Now().UTC().Format(time.RFC3339Nano))
+	field(&b, "level", level.String())
+	field(&b, "service", l.service)
+
+	// Skip write and the exported level method to find the caller.
+	if _, file, line, ok := runtime.Caller(2); ok {
+		field(&b, "caller", fmt.Sprintf("%s/%s:%d", filepath.Base(filepath.Dir(file)), filepath.Base(file), line))
+	}
+
+	if ctx != nil && l.traceIDFn != nil {
+		if traceID := l.traceIDFn(ctx); traceID != "" && traceID != emptyTraceID {
+			field(&b, "trace_id", traceID)
+		}
+	}
+
+	field(&b, "msg", msg)
+	pairs(&b, l.fields)
+	pairs(&b, args)
+	b.WriteString("}\n")
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	l.out.w.Write(b.Bytes())
+}
+
+// pairs writes the alternating keys and values as fields.
+func pairs(b *bytes.Buffer, args []any) {
+	for i := 0; i < len(args); i += 2 {
+		key, ok := args[i].(string)
+		if !ok {
+			key = fmt.Sprint(args[i])
+		}
+
+		if i+1 == len(args) {
+			field(b, "!BADKEY", key)
+			return
+		}
+
+		field(b, key, args[i+1])
+	}
+}
+
+// field writes a single key/value pair into the JSON document.
+func field(b *bytes.Buffer, key string, value any) {
+	if b.Len() > 1 {
+		b.WriteByte(',')
+	}
+
+	k, _ := json.Marshal(key)
+	b.Write(k)
+	b.WriteByte(':')
+
+	switch v := value.(type) {
+	case error:
+		value = v.Error()
+	case json.Marshaler:
+	case time.Duration:
+		value = v.String()
+	case fmt.Stringer:
+		value = v.String()
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprintf("%+v", value))
+	}
+	b.Write(data)
+}
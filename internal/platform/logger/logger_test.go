@@ -0,0 +1,88 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/internal/platform/logger"
+	"github.com/ardanlabs/service/internal/platform/web"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_Logger(t *testing.T) {
+	t.Log("Given the need to write structured log entries.")
+
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen logging inside a request.", testID)
+		{
+			var buf bytes.Buffer
+			log := logger.New(&buf, "SALES-API", logger.LevelInfo, web.GetTraceID).With("version", "1.0")
+
+			v := web.Values{TraceID: "7b2d9c64-7a3e-4a7f-9b0e-3f1c2c9a6d11", Now: time.Now()}
+			ctx := context.WithValue(context.Background(), web.KeyValues, &v)
+
+			log.Debug(ctx, "dropped")
+			log.Error(ctx, "request failed", "error", errors.New("boom"), "since", time.Second, "status", 500)
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if len(lines) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould only write entries at or above the level : %d.", failed, testID, len(lines))
+			}
+			t.Logf("\t%s\tTest %d:\tShould only write entries at or above the level.", success, testID)
+
+			var entry map[string]any
+			if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould write a JSON document : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould write a JSON document.", success, testID)
+
+			exp := map[string]any{
+				"level":    "ERROR",
+				"service":  "SALES-API",
+				"trace_id": v.TraceID,
+				"msg":      "request failed",
+				"version":  "1.0",
+				"error":    "boom",
+				"since":    "1s",
+				"status":   float64(500),
+			}
+			for key, value := range exp {
+				if entry[key] != value {
+					t.Logf("\t\tTest %d:\tGot: %v", testID, entry[key])
+					t.Logf("\t\tTest %d:\tExp: %v", testID, value)
+					t.Fatalf("\t%s\tTest %d:\tShould have the expected value for %q.", failed, testID, key)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould have the expected fields.", success, testID)
+		}
+	}
+
+	{
+		testID := 1
+		t.Logf("\tTest %d:\tWhen logging outside of a request.", testID)
+		{
+			var buf bytes.Buffer
+			logger.New(&buf, "SALES-API", logger.LevelDebug, web.GetTraceID).Debug(context.Background(), "startup")
+
+			var entry map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould write a JSON document : %v.", failed, testID, err)
+			}
+
+			if _, exists := entry["trace_id"]; exists {
+				t.Fatalf("\t%s\tTest %d:\tShould not have a trace id : %v.", failed, testID, entry)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not have a trace id.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// buckets are the upper bounds in seconds of the request latency histogram.
+// They match the defaults of the Prometheus client libraries.
+var buckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// requestKey identifies the series a request is counted in.
+type requestKey struct {
+	method string
+	route  string
+	status int
+}
+
+// latencyKey identifies the latency histogram a request is observed in.
+type latencyKey struct {
+	method string
+	route  string
+}
+
+// histogram tracks the distribution of request latencies. The counts are per
+// bucket and are made cumulative when written.
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// requests holds the per-route request counters and latency histograms. The
+// values are protected by the mutex since there is no expvar equivalent.
+type requests struct {
+	mu        sync.Mutex
+	counters  map[requestKey]uint64
+	latencies map[latencyKey]*histogram
+}
+
+// reqs is the single instance of request metrics, for the same reason the
+// expvar based metrics are a singleton.
+var reqs = requests{
+	counters:  make(map[requestKey]uint64),
+	latencies: make(map[latencyKey]*histogram),
+}
+
+// ObserveRequest counts the request under its method, route and status and
+// records how long it took to handle. The route is the pattern the request
+// matched, not the raw path, to keep the number of series bounded.
+func ObserveRequest(ctx context.Context, method string, route string, status int, d time.Duration) {
+	if _, ok := ctx.Value(key).(*metrics); !ok {
+		return
+	}
+
+	reqs.mu.Lock()
+	defer reqs.mu.Unlock()
+
+	reqs.counters[requestKey{method: method, route: route, status: status}]++
+
+	lk := latencyKey{method: method, route: route}
+	h, exists := reqs.latencies[lk]
+	if !exists {
+		h = &histogram{counts: make([]uint64, len(buckets))}
+		reqs.latencies[lk] = h
+	}
+
+	secs := d.Seconds()
+	for i, le := range buckets {
+		if secs <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += secs
+	h.count++
+}
+
+// =============================================================================
+
+// Handler returns a handler that writes every metric in the Prometheus text
+// exposition format.
+func Handler() http.Handler {
+	f := func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+
+		bw := bufio.NewWriter(w)
+		write(bw)
+		bw.Flush()
+	}
+
+	return http.HandlerFunc(f)
+}
+
+// write writes the metrics in the text exposition format. Series are sorted
+// so the output is stable between scrapes.
+func write(w *bufio.Writer) {
+	header(w, "go_goroutines", "gauge", "Number of goroutines that currently exist.")
+	fmt.Fprintf(w, "go_goroutines %d\n", runtime.NumGoroutine())
+
+	header(w, "app_requests_total", "counter", "Total number of requests handled.")
+	fmt.Fprintf(w, "app_requests_total %d\n", m.requests.Value())
+
+	header(w, "app_errors_total", "counter", "Total number of requests that failed.")
+	fmt.Fprintf(w, "app_errors_total %d\n", m.errors.Value())
+
+	header(w, "app_panics_total", "counter", "Total number of panics recovered.")
+	fmt.Fprintf(w, "app_panics_total %d\n", m.panics.Value())
+
+	reqs.mu.Lock()
+	defer reqs.mu.Unlock()
+
+	rks := make([]requestKey, 0, len(reqs.counters))
+	for rk := range reqs.counters {
+		rks = append(rks, rk)
+	}
+	sort.Slice(rks, func(i, j int) bool {
+		a, b := rks[i], rks[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.status < b.status
+	})
+
+	header(w, "http_requests_total", "counter", "Total number of HTTP requests by method, route and status.")
+	for _, rk := range rks {
+		fmt.Fprintf(w, "http_requests_total{method=%s,route=%s,status=\"%d\"} %d\n",
+			label(rk.method), label(rk.route), rk.status, reqs.counters[rk])
+	}
+
+	lks := make([]latencyKey, 0, len(reqs.latencies))
+	for lk := range reqs.latencies {
+		lks = append(lks, lk)
+	}
+	sort.Slice(lks, func(i, j int) bool {
+		a, b := lks[i], lks[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		return a.method < b.method
+	})
+
+	header(w, "http_request_duration_seconds", "histogram", "Latency of HTTP requests by method and route.")
+	for _, lk := range lks {
+		h := reqs.latencies[lk]
+		labels := fmt.Sprintf("method=%s,route=%s", label(lk.method), label(lk.route))
+
+		var cumulative uint64
+		for i, le := range buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "http_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n",
+				labels, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(w, "http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(w, "http_request_duration_seconds_sum{%s} %s\n", labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "http_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+}
+
+// header writes the HELP and TYPE lines for a metric.
+func header(w *bufio.Writer, name string, typ string, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+}
+
+// labelEscaper escapes a label value as required by the text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// label returns the quoted and escaped label value.
+func label(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
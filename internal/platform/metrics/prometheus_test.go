@@ -0,0 +1,63 @@
+package metrics_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/internal/platform/metrics"
+)
+
+// Success and failure markers.
+const (
+	success = "✓"
+	failed  = "✗"
+)
+
+func Test_Exposition(t *testing.T) {
+	t.Log("Given the need to expose request metrics to Prometheus.")
+	{
+		t.Logf("\tTest 0:\tWhen requests have been observed.")
+		{
+			ctx := metrics.Set(context.Background())
+			metrics.ObserveRequest(ctx, http.MethodGet, "/v1/test/:id", http.StatusOK, 20*time.Millisecond)
+			metrics.ObserveRequest(ctx, http.MethodGet, "/v1/test/:id", http.StatusOK, 2*time.Second)
+			metrics.ObserveRequest(ctx, http.MethodGet, "/v1/test/:id", http.StatusNotFound, time.Millisecond)
+
+			// Requests outside of the metrics middleware are not counted.
+			metrics.ObserveRequest(context.Background(), http.MethodGet, "/v1/test/:id", http.StatusOK, time.Millisecond)
+
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			w := httptest.NewRecorder()
+			metrics.Handler().ServeHTTP(w, r)
+
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+				t.Fatalf("\t%s\tTest 0:\tShould respond with the text exposition format : got %q.", failed, ct)
+			}
+			t.Logf("\t%s\tTest 0:\tShould respond with the text exposition format.", success)
+
+			lines := []string{
+				"# TYPE http_requests_total counter",
+				`http_requests_total{method="GET",route="/v1/test/:id",status="200"} 2`,
+				`http_requests_total{method="GET",route="/v1/test/:id",status="404"} 1`,
+				"# TYPE http_request_duration_seconds histogram",
+				`http_request_duration_seconds_bucket{method="GET",route="/v1/test/:id",le="0.005"} 1`,
+				`http_request_duration_seconds_bucket{method="GET",route="/v1/test/:id",le="0.025"} 2`,
+				`http_request_duration_seconds_bucket{method="GET",route="/v1/test/:id",le="2.5"} 3`,
+				`http_request_duration_seconds_bucket{method="GET",route="/v1/test/:id",le="+Inf"} 3`,
+				`http_request_duration_seconds_count{method="GET",route="/v1/test/:id"} 3`,
+			}
+
+			body := w.Body.String()
+			for _, line := range lines {
+				if !strings.Contains(body, line+"\n") {
+					t.Fatalf("\t%s\tTest 0:\tShould contain %q :\n%s", failed, line, body)
+				}
+			}
+			t.Logf("\t%s\tTest 0:\tShould contain the counters and histogram buckets.", success)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Package tracing configures the OpenTelemetry SDK tracer provider used by
+// the service. It picks the sampler, the resource describing the service and
+// the exporters, so the rest of the service only depends on the OpenTelemetry
+// API.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+)
+
+// Config declares how the provider samples and exports spans.
+type Config struct {
+	ServiceName string
+	Probability float64
+	Exporter    sdktrace.SpanExporter
+}
+
+// NewProvider constructs a tracer provider that samples the probability of
+// new traces, always follows the sampling decision of a parent span, and
+// exports recorded spans in batches. Without an exporter spans are still
+// sampled but dropped. Call Shutdown to export the remaining spans before
+// the program exits.
+func NewProvider(cfg Config) *sdktrace.TracerProvider {
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.Probability))),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(cfg.ServiceName),
+		)),
+	}
+
+	if cfg.Exporter != nil {
+		options = append(options, sdktrace.WithBatcher(cfg.Exporter))
+	}
+
+	return sdktrace.NewTracerProvider(options...)
+}
+
+// NewWriterExporter constructs an exporter that writes each span as a JSON
+// document on its own line. It is meant for local development where spans
+// go to stdout or a file. The exporter does not close w.
+func NewWriterExporter(w io.Writer) (sdktrace.SpanExporter, error) {
+	return stdouttrace.New(stdouttrace.WithWriter(w))
+}
+
+// NewOTLPExporter constructs an exporter that sends spans to the collector
+// listening at the endpoint, for example http://localhost:4318, using the
+// OTLP/HTTP protocol. A path on the endpoint replaces the default
+// /v1/traces.
+func NewOTLPExporter(ctx context.Context, endpoint string) (sdktrace.SpanExporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid otlp endpoint %q", endpoint)
+	}
+
+	options := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+	}
+
+	switch u.Scheme {
+	case "http":
+		options = append(options, otlptracehttp.WithInsecure())
+	case "https":
+	default:
+		return nil, fmt.Errorf("invalid otlp endpoint scheme %q", u.Scheme)
+	}
+
+	if u.Path != "" && u.Path != "/" {
+		options = append(options, otlptracehttp.WithURLPath(u.Path))
+	}
+
+	return otlptracehttp.New(ctx, options...)
+}
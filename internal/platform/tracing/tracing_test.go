@@ -0,0 +1,220 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ardanlabs/service/internal/platform/tracing"
+	"github.com/ardanlabs/service/internal/platform/web"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Success and failure markers.
+const (
+	success = "✓"
+	failed  = "✗"
+)
+
+func Test_Sampling(t *testing.T) {
+	t.Log("Given the need to sample traces.")
+	{
+		t.Logf("\tTest 0:\tWhen starting spans with a probability of zero.")
+		{
+			rec := tracetest.NewInMemoryExporter()
+			p := tracing.NewProvider(tracing.Config{ServiceName: "test", Probability: 0, Exporter: rec})
+
+			_, span := p.Tracer("test").Start(context.Background(), "root")
+			if !span.SpanContext().IsValid() {
+				t.Fatalf("\t%s\tTest 0:\tShould get a valid span context.", failed)
+			}
+			t.Logf("\t%s\tTest 0:\tShould get a valid span context.", success)
+
+			if span.IsRecording() {
+				t.Fatalf("\t%s\tTest 0:\tShould not record the span.", failed)
+			}
+			t.Logf("\t%s\tTest 0:\tShould not record the span.", success)
+
+			span.End()
+			p.Shutdown(context.Background())
+
+			if spans := rec.GetSpans(); len(spans) != 0 {
+				t.Fatalf("\t%s\tTest 0:\tShould not export the span : got %d.", failed, len(spans))
+			}
+			t.Logf("\t%s\tTest 0:\tShould not export the span.", success)
+		}
+
+		t.Logf("\tTest 1:\tWhen starting a child of a sampled parent.")
+		{
+			rec := tracetest.NewInMemoryExporter()
+			p := tracing.NewProvider(tracing.Config{ServiceName: "test", Probability: 0, Exporter: rec})
+
+			parent := trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID:    trace.TraceID{1},
+				SpanID:     trace.SpanID{2},
+				TraceFlags: trace.FlagsSampled,
+				Remote:     true,
+			})
+			ctx := trace.ContextWithRemoteSpanContext(context.Background(), parent)
+
+			_, span := p.Tracer("test").Start(ctx, "child")
+			span.End()
+
+			if err := p.ForceFlush(context.Background()); err != nil {
+				t.Fatalf("\t%s\tTest 1:\tShould be able to flush the spans : %v.", failed, err)
+			}
+			t.Logf("\t%s\tTest 1:\tShould be able to flush the spans.", success)
+
+			spans := rec.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("\t%s\tTest 1:\tShould export the span : got %d.", failed, len(spans))
+			}
+			t.Logf("\t%s\tTest 1:\tShould export the span.", success)
+
+			sd := spans[0]
+			if sd.SpanContext.TraceID() != parent.TraceID() || sd.Parent.SpanID() != parent.SpanID() {
+				t.Fatalf("\t%s\tTest 1:\tShould join the trace of the parent : %s/%s.", failed, sd.SpanContext.TraceID(), sd.Parent.SpanID())
+			}
+			t.Logf("\t%s\tTest 1:\tShould join the trace of the parent.", success)
+
+			p.Shutdown(context.Background())
+		}
+	}
+}
+
+func Test_Request(t *testing.T) {
+	t.Log("Given the need to trace each request.")
+	{
+		t.Logf("\tTest 0:\tWhen a request carries a traceparent header.")
+		{
+			var buf bytes.Buffer
+			exp, err := tracing.NewWriterExporter(&buf)
+			if err != nil {
+				t.Fatalf("\t%s\tTest 0:\tShould be able to construct the exporter : %v.", failed, err)
+			}
+			p := tracing.NewProvider(tracing.Config{ServiceName: "test", Probability: 1, Exporter: exp})
+
+			app := web.New(make(chan os.Signal, 1), p.Tracer("test"))
+			app.Handle(http.MethodGet, "v1", "/users/:id", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				_, span := web.AddSpan(ctx, "handler")
+				span.End()
+				return web.Respond(ctx, w, nil, http.StatusNoContent)
+			})
+
+			const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/users/123", nil)
+			r.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if got := w.Header().Get(web.TraceIDHeader); got != traceID {
+				t.Fatalf("\t%s\tTest 0:\tShould respond with the trace id of the caller : got %q.", failed, got)
+			}
+			t.Logf("\t%s\tTest 0:\tShould respond with the trace id of the caller.", success)
+
+			p.Shutdown(context.Background())
+
+			var names []string
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				var doc struct {
+					Name        string
+					SpanContext struct {
+						TraceID string
+					}
+				}
+				if err := json.Unmarshal([]byte(line), &doc); err != nil {
+					t.Fatalf("\t%s\tTest 0:\tShould be able to decode the span : %v.", failed, err)
+				}
+				if doc.SpanContext.TraceID != traceID {
+					t.Fatalf("\t%s\tTest 0:\tShould write spans in the trace of the caller : got %q.", failed, doc.SpanContext.TraceID)
+				}
+				names = append(names, doc.Name)
+			}
+			t.Logf("\t%s\tTest 0:\tShould write spans in the trace of the caller.", success)
+
+			if !contains(names, "GET /v1/users/:id") || !contains(names, "handler") {
+				t.Fatalf("\t%s\tTest 0:\tShould write the server and handler spans : got %v.", failed, names)
+			}
+			t.Logf("\t%s\tTest 0:\tShould write the server and handler spans.", success)
+		}
+	}
+}
+
+func Test_OTLP(t *testing.T) {
+	t.Log("Given the need to send spans to an OpenTelemetry collector.")
+	{
+		t.Logf("\tTest 0:\tWhen the provider shuts down.")
+		{
+			requests := make(chan *http.Request, 1)
+			bodies := make(chan []byte, 1)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				requests <- r
+				bodies <- body
+			}))
+			defer srv.Close()
+
+			exp, err := tracing.NewOTLPExporter(context.Background(), srv.URL)
+			if err != nil {
+				t.Fatalf("\t%s\tTest 0:\tShould be able to construct the exporter : %v.", failed, err)
+			}
+			t.Logf("\t%s\tTest 0:\tShould be able to construct the exporter.", success)
+
+			p := tracing.NewProvider(tracing.Config{ServiceName: "sales-api", Probability: 1, Exporter: exp})
+			_, span := p.Tracer("test").Start(context.Background(), "root")
+			span.End()
+
+			if err := p.Shutdown(context.Background()); err != nil {
+				t.Fatalf("\t%s\tTest 0:\tShould be able to shut down : %v.", failed, err)
+			}
+
+			r := <-requests
+			if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+				t.Fatalf("\t%s\tTest 0:\tShould post protobuf to /v1/traces : got %s %s %s.", failed, r.Method, r.URL.Path, r.Header.Get("Content-Type"))
+			}
+			t.Logf("\t%s\tTest 0:\tShould post protobuf to /v1/traces.", success)
+
+			var req coltracepb.ExportTraceServiceRequest
+			if err := proto.Unmarshal(<-bodies, &req); err != nil {
+				t.Fatalf("\t%s\tTest 0:\tShould send an export request : %v.", failed, err)
+			}
+
+			rs := req.GetResourceSpans()
+			if len(rs) != 1 || len(rs[0].GetScopeSpans()) != 1 || len(rs[0].GetScopeSpans()[0].GetSpans()) != 1 {
+				t.Fatalf("\t%s\tTest 0:\tShould send the span : %v.", failed, &req)
+			}
+
+			var service string
+			for _, kv := range rs[0].GetResource().GetAttributes() {
+				if kv.GetKey() == "service.name" {
+					service = kv.GetValue().GetStringValue()
+				}
+			}
+
+			sent := rs[0].GetScopeSpans()[0].GetSpans()[0]
+			if sent.GetName() != "root" || service != "sales-api" {
+				t.Fatalf("\t%s\tTest 0:\tShould send the span of the service : got %q from %q.", failed, sent.GetName(), service)
+			}
+			t.Logf("\t%s\tTest 0:\tShould send the span of the service.", success)
+		}
+	}
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -12,10 +12,15 @@ type ctxKey int
 
 const key ctxKey = 1
 
+// emptyTraceID is reported when the context isn't carrying request values.
+var emptyTraceID = trace.TraceID{}.String()
+
 // Values represent state for each request.
 type Values struct {
 	TraceID    string
 	Tracer     trace.Tracer
+	Route      string
+	Accept     string
 	Now        time.Time
 	StatusCode int
 }
@@ -25,7 +30,7 @@ func GetValues(ctx context.Context) *Values {
 	v, ok := ctx.Value(key).(*Values)
 	if !ok {
 		return &Values{
-			TraceID: "00000000-0000-0000-0000-000000000000",
+			TraceID: emptyTraceID,
 			Tracer:  trace.NewNoopTracerProvider().Tracer(""),
 			Now:     time.Now(),
 		}
@@ -38,7 +43,7 @@ func GetValues(ctx context.Context) *Values {
 func GetTraceID(ctx context.Context) string {
 	v, ok := ctx.Value(key).(*Values)
 	if !ok {
-		return "00000000-0000-0000-0000-000000000000"
+		return emptyTraceID
 	}
 	return v.TraceID
 }
@@ -0,0 +1,367 @@
+package web
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Encoder writes a value to the writer in a given format.
+type Encoder func(w io.Writer, data any) error
+
+// Tabular is implemented by values whose rows are not the value itself,
+// such as a page of items in an envelope. Tabular formats like CSV encode
+// just the rows.
+type Tabular interface {
+	TableRows() any
+}
+
+// TableRows implements the Tabular interface so a page is encoded as its
+// items.
+func (pd PageDocument[T]) TableRows() any {
+	return pd.Items
+}
+
+// encodeJSON writes the value as a JSON document.
+func encodeJSON(w io.Writer, data any) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(jsonData)
+	return err
+}
+
+// toGeneric converts the value to the maps, slices and scalars its JSON
+// form decodes to. The other formats are built from this so every format
+// uses the same field names as JSON.
+func toGeneric(data any) (any, error) {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.UseNumber()
+
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// sortedKeys returns the keys of the object in order.
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// =============================================================================
+
+// encodeXML writes the value as an XML document with a response root
+// element. Objects become child elements named after their keys and arrays
+// become repeated item elements.
+func encodeXML(w io.Writer, data any) error {
+	v, err := toGeneric(data)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	if err := writeXML(enc, "response", v); err != nil {
+		return err
+	}
+
+	return enc.Flush()
+}
+
+// writeXML writes the value as an element with the name.
+func writeXML(enc *xml.Encoder, name string, v any) error {
+	start := xml.StartElement{Name: xml.Name{Local: xmlName(name)}}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch val := v.(type) {
+	case map[string]any:
+		for _, k := range sortedKeys(val) {
+			if err := writeXML(enc, k, val[k]); err != nil {
+				return err
+			}
+		}
+
+	case []any:
+		for _, item := range val {
+			if err := writeXML(enc, "item", item); err != nil {
+				return err
+			}
+		}
+
+	case nil:
+
+	default:
+		if err := enc.EncodeToken(xml.CharData(scalarString(val))); err != nil {
+			return err
+		}
+	}
+
+	return enc.EncodeToken(start.End())
+}
+
+// xmlName replaces the characters that can't be used in an element name.
+func xmlName(name string) string {
+	b := []rune(name)
+	for i, r := range b {
+		switch {
+		case r == '_' || unicode.IsLetter(r):
+		case i > 0 && (r == '-' || r == '.' || unicode.IsDigit(r)):
+		default:
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
+
+// =============================================================================
+
+// encodeCSV writes the value as CSV with a header row. An array of objects
+// becomes one row per object with a column per key, a single object becomes
+// one row. Nested values are written as JSON.
+func encodeCSV(w io.Writer, data any) error {
+	if t, ok := data.(Tabular); ok {
+		data = t.TableRows()
+	}
+
+	v, err := toGeneric(data)
+	if err != nil {
+		return err
+	}
+
+	items, ok := v.([]any)
+	if !ok {
+		items = []any{v}
+	}
+
+	rows := make([]map[string]any, 0, len(items))
+	columns := make(map[string]any)
+	for _, item := range items {
+		row, ok := item.(map[string]any)
+		if !ok {
+			row = map[string]any{"value": item}
+		}
+		for k := range row {
+			columns[k] = nil
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	header := sortedKeys(columns)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(header))
+	for _, row := range rows {
+		for i, k := range header {
+			cell, err := csvCell(row[k])
+			if err != nil {
+				return err
+			}
+			record[i] = cell
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvCell formats a value for a single CSV cell.
+func csvCell(v any) (string, error) {
+	switch v.(type) {
+	case map[string]any, []any:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	return scalarString(v), nil
+}
+
+// scalarString formats a scalar JSON value as text.
+func scalarString(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case bool:
+		return strconv.FormatBool(val)
+	}
+	return fmt.Sprint(v)
+}
+
+// =============================================================================
+
+// encodeMsgPack writes the value in the MessagePack format. Map keys are
+// written in order so the output is stable.
+func encodeMsgPack(w io.Writer, data any) error {
+	v, err := toGeneric(data)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := writeMsgPack(&buf, v); err != nil {
+		return err
+	}
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// writeMsgPack appends the value to the buffer.
+func writeMsgPack(buf *bytes.Buffer, v any) error {
+	switch val := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+
+	case bool:
+		if val {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			writeMsgPackInt(buf, i)
+			return nil
+		}
+		if u, err := strconv.ParseUint(val.String(), 10, 64); err == nil {
+			buf.WriteByte(0xcf)
+			binary.Write(buf, binary.BigEndian, u)
+			return nil
+		}
+		f, err := val.Float64()
+		if err != nil {
+			return err
+		}
+		buf.WriteByte(0xcb)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+
+	case string:
+		if !utf8.ValidString(val) {
+			return fmt.Errorf("msgpack: invalid utf8 string")
+		}
+		n := len(val)
+		switch {
+		case n < 32:
+			buf.WriteByte(0xa0 | byte(n))
+		case n <= math.MaxUint8:
+			buf.WriteByte(0xd9)
+			buf.WriteByte(byte(n))
+		case n <= math.MaxUint16:
+			buf.WriteByte(0xda)
+			binary.Write(buf, binary.BigEndian, uint16(n))
+		default:
+			buf.WriteByte(0xdb)
+			binary.Write(buf, binary.BigEndian, uint32(n))
+		}
+		buf.WriteString(val)
+
+	case []any:
+		writeMsgPackLen(buf, len(val), 0x90, 0xdc, 0xdd)
+		for _, item := range val {
+			if err := writeMsgPack(buf, item); err != nil {
+				return err
+			}
+		}
+
+	case map[string]any:
+		writeMsgPackLen(buf, len(val), 0x80, 0xde, 0xdf)
+		for _, k := range sortedKeys(val) {
+			if err := writeMsgPack(buf, k); err != nil {
+				return err
+			}
+			if err := writeMsgPack(buf, val[k]); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("msgpack: unsupported type %T", v)
+	}
+
+	return nil
+}
+
+// writeMsgPackInt writes the integer in its smallest form.
+func writeMsgPackInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0 && i <= 127:
+		buf.WriteByte(byte(i))
+	case i < 0 && i >= -32:
+		buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		buf.WriteByte(0xd1)
+		binary.Write(buf, binary.BigEndian, int16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		buf.WriteByte(0xd2)
+		binary.Write(buf, binary.BigEndian, int32(i))
+	default:
+		buf.WriteByte(0xd3)
+		binary.Write(buf, binary.BigEndian, i)
+	}
+}
+
+// writeMsgPackLen writes the header of an array or map of n elements.
+func writeMsgPackLen(buf *bytes.Buffer, n int, fix byte, code16 byte, code32 byte) {
+	switch {
+	case n < 16:
+		buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(code16)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(code32)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
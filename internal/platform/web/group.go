@@ -0,0 +1,60 @@
+package web
+
+import (
+	"strings"
+)
+
+// Group is a set of routes sharing a path prefix and middleware. Groups can
+// be nested, each adding to the prefix and middleware of its parent.
+type Group struct {
+	app    *App
+	prefix string
+	mw     []Middleware
+}
+
+// Group creates a group of routes under the prefix. The middleware runs
+// after the application-wide middleware and before any route middleware.
+func (a *App) Group(prefix string, mw ...Middleware) *Group {
+	return &Group{
+		app:    a,
+		prefix: cleanPrefix(prefix),
+		mw:     mw,
+	}
+}
+
+// Group creates a group nested inside this one. The middleware runs after
+// the middleware of this group.
+func (g *Group) Group(prefix string, mw ...Middleware) *Group {
+	return &Group{
+		app:    g.app,
+		prefix: g.prefix + cleanPrefix(prefix),
+		mw:     joinMiddleware(g.mw, mw),
+	}
+}
+
+// Handle mounts the handler for the HTTP verb and the path under the prefix
+// of the group.
+func (g *Group) Handle(method string, path string, handler Handler, mw ...Middleware) {
+	g.app.Handle(method, "", g.prefix+path, handler, joinMiddleware(g.mw, mw)...)
+}
+
+// cleanPrefix makes sure a prefix starts with a slash and doesn't end with
+// one. An empty prefix stays empty so a group can only add middleware.
+func cleanPrefix(prefix string) string {
+	prefix = strings.TrimRight(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return prefix
+}
+
+// joinMiddleware returns a new slice with the outer middleware followed by
+// the inner middleware, so groups never share a backing array.
+func joinMiddleware(outer []Middleware, inner []Middleware) []Middleware {
+	mw := make([]Middleware, 0, len(outer)+len(inner))
+	mw = append(mw, outer...)
+	return append(mw, inner...)
+}
@@ -0,0 +1,58 @@
+package web_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ardanlabs/service/internal/platform/web"
+)
+
+// record returns middleware that appends its name to the trail.
+func record(name string, trail *[]string) web.Middleware {
+	return func(handler web.Handler) web.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			*trail = append(*trail, name)
+			return handler(ctx, w, r)
+		}
+	}
+}
+
+func Test_Group(t *testing.T) {
+	t.Log("Given the need to group routes with shared middleware.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a route in a nested group.", testID)
+		{
+			var trail []string
+
+			app := web.New(nil, nil, record("app", &trail))
+			v1 := app.Group("/v1", record("v1", &trail))
+			admin := v1.Group("admin/", record("admin", &trail), record("admin2", &trail))
+			v1.Group("/other", record("other", &trail))
+
+			h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				trail = append(trail, "handler:"+web.GetValues(ctx).Route)
+				return web.Respond(ctx, w, nil, http.StatusNoContent)
+			}
+			admin.Handle(http.MethodGet, "/users/:id", h, record("route", &trail))
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/admin/users/42", nil)
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould route to the handler : got %d.", failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould route to the handler.", success, testID)
+
+			exp := "app,v1,admin,admin2,route,handler:/v1/admin/users/:id"
+			if got := strings.Join(trail, ","); got != exp {
+				t.Fatalf("\t%s\tTest %d:\tShould run the middleware in order : got %s.", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould run the middleware in order.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ardanlabs/service/business/sys/validate"
+)
+
+// Set of media types supported out of the box.
+const (
+	MediaTypeJSON    = "application/json"
+	MediaTypeXML     = "application/xml"
+	MediaTypeCSV     = "text/csv"
+	MediaTypeMsgPack = "application/msgpack"
+	MediaTypeJWKSet  = "application/jwk-set+json"
+)
+
+// registry holds the encoders Respond can choose from, along with the order
+// they were registered in. The first one is used when the client accepts
+// anything.
+var registry = struct {
+	mu       sync.RWMutex
+	encoders map[string]Encoder
+	order    []string
+}{
+	encoders: make(map[string]Encoder),
+}
+
+func init() {
+	RegisterEncoder(MediaTypeJSON, encodeJSON)
+	RegisterEncoder(MediaTypeXML, encodeXML)
+	RegisterEncoder("text/xml", encodeXML)
+	RegisterEncoder(MediaTypeCSV, encodeCSV)
+	RegisterEncoder(MediaTypeMsgPack, encodeMsgPack)
+	RegisterEncoder("application/x-msgpack", encodeMsgPack)
+	RegisterEncoder("application/vnd.msgpack", encodeMsgPack)
+	RegisterEncoder(MediaTypeJWKSet, encodeJSON)
+}
+
+// RegisterEncoder makes an encoder available to Respond for the media type.
+// Registering a media type again replaces its encoder.
+func RegisterEncoder(mediaType string, enc Encoder) {
+	mediaType = strings.ToLower(mediaType)
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if _, exists := registry.encoders[mediaType]; !exists {
+		registry.order = append(registry.order, mediaType)
+	}
+	registry.encoders[mediaType] = enc
+}
+
+// lookupEncoder returns the encoder registered for the media type.
+func lookupEncoder(mediaType string) (Encoder, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	enc, exists := registry.encoders[strings.ToLower(mediaType)]
+	return enc, exists
+}
+
+// mediaRange is one entry of an Accept header.
+type mediaRange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+// negotiate picks the registered media type that best matches the Accept
+// header. An empty header accepts anything.
+func negotiate(accept string) (string, Encoder, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	mediaType, ok := bestMatch(accept, registry.order)
+	if !ok {
+		return "", nil, false
+	}
+
+	return mediaType, registry.encoders[mediaType], true
+}
+
+// Negotiate returns the media type of the offers that best matches the
+// Accept header of the request, for handlers that can only send a few
+// formats. Earlier offers are preferred when the client accepts several
+// equally. It reports false when the client accepts none of them.
+func Negotiate(ctx context.Context, offers ...string) (string, bool) {
+	return bestMatch(GetValues(ctx).Accept, offers)
+}
+
+// bestMatch picks the media type of the candidates that best matches the
+// Accept header. An empty header accepts anything.
+func bestMatch(accept string, candidates []string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		accept = "*/*"
+	}
+
+	ranges := parseAccept(accept)
+
+	for _, mr := range ranges {
+		if mr.q == 0 {
+			continue
+		}
+
+		for _, mediaType := range candidates {
+			if mr.matches(mediaType) && quality(ranges, mediaType) > 0 {
+				return mediaType, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// matches reports if the media type is part of the range.
+func (mr mediaRange) matches(mediaType string) bool {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+	return (mr.typ == "*" || mr.typ == typ) && (mr.subtype == "*" || mr.subtype == subtype)
+}
+
+// specificity ranks exact media types above type/* and */*.
+func (mr mediaRange) specificity() int {
+	switch {
+	case mr.typ == "*":
+		return 0
+	case mr.subtype == "*":
+		return 1
+	}
+	return 2
+}
+
+// quality returns the quality the client gives the media type, taken from
+// the most specific range that matches it. A quality of zero means the
+// client refuses the media type.
+func quality(ranges []mediaRange, mediaType string) float64 {
+	best := -1
+	var q float64
+	for _, mr := range ranges {
+		if mr.matches(mediaType) && mr.specificity() > best {
+			best, q = mr.specificity(), mr.q
+		}
+	}
+	return q
+}
+
+// parseAccept parses the media ranges of an Accept header, ordered by
+// quality and then by how specific they are. Invalid ranges are ignored.
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		typ, subtype, ok := strings.Cut(mt, "/")
+		if !ok {
+			continue
+		}
+
+		q := 1.0
+		if v, exists := params["q"]; exists {
+			if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+
+		ranges = append(ranges, mediaRange{typ: typ, subtype: subtype, q: q})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+
+	return ranges
+}
+
+// notAcceptable is the error returned when no registered media type matches
+// the Accept header.
+func notAcceptable(accept string) error {
+	registry.mu.RLock()
+	supported := strings.Join(registry.order, ", ")
+	registry.mu.RUnlock()
+
+	err := fmt.Errorf("none of the accepted media types %q are supported, expecting one of %s", accept, supported)
+	return validate.NewRequestError(err, http.StatusNotAcceptable)
+}
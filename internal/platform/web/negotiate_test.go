@@ -0,0 +1,118 @@
+package web_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/internal/platform/web"
+)
+
+type product struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Price int    `json:"price"`
+}
+
+func Test_Negotiate(t *testing.T) {
+	t.Log("Given the need to respond in the format the client accepts.")
+
+	page := web.PageDocument[product]{
+		Items: []product{
+			{ID: "1", Name: "Comic Books", Price: 50},
+			{ID: "2", Name: "McDonalds Toys, Used", Price: 75},
+		},
+		Total:       2,
+		Page:        1,
+		RowsPerPage: 10,
+	}
+
+	tt := []struct {
+		name   string
+		path   string
+		accept string
+		status int
+		ctype  string
+		body   string
+	}{
+		{"no accept header", "/products", "", http.StatusOK, "application/json", `{"items":[{"id":"1"`},
+		{"any format", "/products", "*/*", http.StatusOK, "application/json", `"total":2`},
+		{"xml", "/products", "application/xml", http.StatusOK, "application/xml", "<response><items><item><id>1</id><name>Comic Books</name><price>50</price></item>"},
+		{"csv", "/products", "text/csv", http.StatusOK, "text/csv; charset=utf-8", "id,name,price\n1,Comic Books,50\n2,\"McDonalds Toys, Used\",75\n"},
+		{"msgpack", "/products", "application/msgpack", http.StatusOK, "application/msgpack", "\x84\xa5items\x92\x83\xa2id\xa11\xa4name\xabComic Books\xa5price\x32"},
+		{"preferred format", "/products", "text/html, application/xml;q=0.9, application/json;q=0.8", http.StatusOK, "application/xml", "<response>"},
+		{"refused format", "/products", "application/*, application/json;q=0", http.StatusOK, "application/xml", "<response>"},
+		{"unsupported format", "/products", "text/html", http.StatusNotAcceptable, "application/json", `"error":"none of the accepted media types`},
+		{"key set", "/products", "application/jwk-set+json", http.StatusOK, "application/jwk-set+json", `{"items":[{"id":"1"`},
+		{"forced format", "/export", "application/json", http.StatusOK, "text/csv; charset=utf-8", "id,name,price\n"},
+		{"forced format in an unsupported format", "/export", "text/html", http.StatusOK, "text/csv; charset=utf-8", "id,name,price\n"},
+		{"error in xml", "/fail", "application/xml", http.StatusBadRequest, "application/xml", "<fields><name>required</name></fields>"},
+		{"error in unsupported format", "/fail", "text/html", http.StatusBadRequest, "application/json", `"error":"data validation error"`},
+		{"no content", "/empty", "application/xml", http.StatusNoContent, "", ""},
+	}
+
+	for testID, tst := range tt {
+		t.Logf("\tTest %d:\tWhen asking for %s.", testID, tst.name)
+		{
+			r := httptest.NewRequest(http.MethodGet, tst.path, nil)
+			if tst.accept != "" {
+				r.Header.Set("Accept", tst.accept)
+			}
+			w := httptest.NewRecorder()
+
+			// errs mimics mid.Errors so request errors become responses.
+			var handlerErr error
+			errs := func(handler web.Handler) web.Handler {
+				return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+					if handlerErr = handler(ctx, w, r); handlerErr != nil {
+						re := validate.GetRequestError(handlerErr)
+						if re == nil {
+							return handlerErr
+						}
+						return web.Respond(ctx, w, validate.ErrorResponse{Error: re.Error()}, re.Status)
+					}
+					return nil
+				}
+			}
+			app := web.New(nil, nil, errs)
+			app.Handle(http.MethodGet, "", tst.path, func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				switch tst.path {
+				case "/empty":
+					return web.Respond(ctx, w, nil, http.StatusNoContent)
+				case "/export":
+					return web.RespondAs(ctx, w, page, http.StatusOK, web.MediaTypeCSV)
+				case "/fail":
+					er := validate.ErrorResponse{Error: "data validation error", Fields: map[string]string{"name": "required"}}
+					return web.Respond(ctx, w, er, http.StatusBadRequest)
+				}
+				return web.Respond(ctx, w, page, http.StatusOK)
+			})
+			app.ServeHTTP(w, r)
+
+			if w.Code != tst.status {
+				t.Fatalf("\t%s\tTest %d:\tShould respond with %d : got %d : %s : %v.", failed, testID, tst.status, w.Code, w.Body.String(), handlerErr)
+			}
+			t.Logf("\t%s\tTest %d:\tShould respond with %d.", success, testID, tst.status)
+
+			if tst.status == http.StatusNoContent {
+				if w.Header().Get("Content-Type") != "" || w.Header().Get("Vary") != "" || w.Body.Len() != 0 {
+					t.Fatalf("\t%s\tTest %d:\tShould send no content : got %v %q.", failed, testID, w.Header(), w.Body.String())
+				}
+				t.Logf("\t%s\tTest %d:\tShould send no content.", success, testID)
+				continue
+			}
+
+			if ct := w.Header().Get("Content-Type"); ct != tst.ctype {
+				t.Fatalf("\t%s\tTest %d:\tShould respond with %s : got %s.", failed, testID, tst.ctype, ct)
+			}
+			if !bytes.Contains(w.Body.Bytes(), []byte(tst.body)) {
+				t.Fatalf("\t%s\tTest %d:\tShould contain %q : got %q.", failed, testID, tst.body, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould encode the %s response.", success, testID, strings.Split(tst.ctype, ";")[0])
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ardanlabs/service/business/sys/validate"
+)
+
+// Set of directions for data ordering.
+const (
+	ASC  = "ASC"
+	DESC = "DESC"
+)
+
+// Set of query string keys used for paging and ordering.
+const (
+	queryPage    = "page"
+	queryRows    = "rows"
+	queryOrderBy = "orderBy"
+)
+
+// OrderBy represents a field used to order by and direction.
+type OrderBy struct {
+	Field     string
+	Direction string
+}
+
+// QueryConfig declares what a list endpoint accepts in its query string.
+// OrderFields maps the names clients may use in orderBy to the field names
+// the business layer understands. Filters lists the query keys that are
+// passed through as field filters.
+type QueryConfig struct {
+	OrderFields  map[string]string
+	DefaultOrder OrderBy
+	Filters      []string
+	DefaultRows  int
+	MaxRows      int
+}
+
+// Query represents the paging, ordering and filtering values parsed from a
+// request's query string.
+type Query struct {
+	Page        int
+	RowsPerPage int
+	OrderBy     OrderBy
+	Filters     map[string]string
+}
+
+// ParseQuery parses the query string for a list endpoint using the provided
+// config. Bad values are reported as validate.FieldErrors.
+//
+// Example: /v1/users?page=2&rows=20&orderBy=name,DESC&role=ADMIN
+func ParseQuery(r *http.Request, cfg QueryConfig) (Query, error) {
+	if cfg.DefaultRows <= 0 {
+		cfg.DefaultRows = 10
+	}
+	if cfg.MaxRows <= 0 {
+		cfg.MaxRows = 100
+	}
+
+	values := r.URL.Query()
+	var fields validate.FieldErrors
+
+	q := Query{
+		Page:        1,
+		RowsPerPage: cfg.DefaultRows,
+		OrderBy:     cfg.DefaultOrder,
+		Filters:     make(map[string]string),
+	}
+
+	if v := values.Get(queryPage); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 {
+			fields = append(fields, validate.FieldError{Field: queryPage, Error: "page must be a number greater than 0"})
+		}
+		q.Page = page
+	}
+
+	if v := values.Get(queryRows); v != "" {
+		rows, err := strconv.Atoi(v)
+		if err != nil || rows < 1 || rows > cfg.MaxRows {
+			fields = append(fields, validate.FieldError{Field: queryRows, Error: fmt.Sprintf("rows must be a number between 1 and %d", cfg.MaxRows)})
+		}
+		q.RowsPerPage = rows
+	}
+
+	if v := values.Get(queryOrderBy); v != "" {
+		orderBy, err := parseOrderBy(v, cfg.OrderFields)
+		if err != nil {
+			fields = append(fields, validate.FieldError{Field: queryOrderBy, Error: err.Error()})
+		}
+		q.OrderBy = orderBy
+	}
+
+	for _, key := range cfg.Filters {
+		if v := values.Get(key); v != "" {
+			q.Filters[key] = v
+		}
+	}
+
+	if len(fields) > 0 {
+		return Query{}, fields
+	}
+
+	return q, nil
+}
+
+// Offset returns the number of rows to skip for the requested page.
+func (q Query) Offset() int {
+	return (q.Page - 1) * q.RowsPerPage
+}
+
+// parseOrderBy parses a value in the form "field" or "field,direction" and
+// maps the field to its business layer name.
+func parseOrderBy(v string, orderFields map[string]string) (OrderBy, error) {
+	parts := strings.Split(v, ",")
+	if len(parts) > 2 {
+		return OrderBy{}, fmt.Errorf("orderBy must be in the form field[,%s|%s]", ASC, DESC)
+	}
+
+	field, exists := orderFields[strings.TrimSpace(parts[0])]
+	if !exists {
+		names := make([]string, 0, len(orderFields))
+		for name := range orderFields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return OrderBy{}, fmt.Errorf("orderBy field must be one of %v", names)
+	}
+
+	orderBy := OrderBy{
+		Field:     field,
+		Direction: ASC,
+	}
+
+	if len(parts) == 2 {
+		switch dir := strings.ToUpper(strings.TrimSpace(parts[1])); dir {
+		case ASC, DESC:
+			orderBy.Direction = dir
+		default:
+			return OrderBy{}, fmt.Errorf("orderBy direction must be %s or %s", ASC, DESC)
+		}
+	}
+
+	return orderBy, nil
+}
+
+// =============================================================================
+
+// PageDocument is the response envelope used by list endpoints.
+type PageDocument[T any] struct {
+	Items       []T `json:"items"`
+	Total       int `json:"total"`
+	Page        int `json:"page"`
+	RowsPerPage int `json:"rowsPerPage"`
+}
+
+// NewPageDocument constructs a response value for a page of items.
+func NewPageDocument[T any](items []T, total int, q Query) PageDocument[T] {
+	if items == nil {
+		items = []T{}
+	}
+
+	return PageDocument[T]{
+		Items:       items,
+		Total:       total,
+		Page:        q.Page,
+		RowsPerPage: q.RowsPerPage,
+	}
+}
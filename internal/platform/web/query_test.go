@@ -0,0 +1,95 @@
+package web_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/internal/platform/web"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+var queryConfig = web.QueryConfig{
+	OrderFields:  map[string]string{"name": "name", "id": "user_id"},
+	DefaultOrder: web.OrderBy{Field: "user_id", Direction: web.ASC},
+	Filters:      []string{"role"},
+	MaxRows:      50,
+}
+
+func Test_ParseQuery(t *testing.T) {
+	t.Log("Given the need to parse paging, ordering and filtering values.")
+
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a valid query string.", testID)
+		{
+			r := httptest.NewRequest("GET", "/v1/users?page=2&rows=20&orderBy=name,desc&role=ADMIN&other=x", nil)
+
+			q, err := web.ParseQuery(r, queryConfig)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to parse the query : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to parse the query.", success, testID)
+
+			if q.Page != 2 || q.RowsPerPage != 20 || q.Offset() != 20 {
+				t.Fatalf("\t%s\tTest %d:\tShould get the requested page : %+v.", failed, testID, q)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the requested page.", success, testID)
+
+			exp := web.OrderBy{Field: "name", Direction: web.DESC}
+			if q.OrderBy != exp {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, q.OrderBy)
+				t.Logf("\t\tTest %d:\tExp: %v", testID, exp)
+				t.Fatalf("\t%s\tTest %d:\tShould get the requested order.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the requested order.", success, testID)
+
+			if len(q.Filters) != 1 || q.Filters["role"] != "ADMIN" {
+				t.Fatalf("\t%s\tTest %d:\tShould only get the declared filters : %v.", failed, testID, q.Filters)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only get the declared filters.", success, testID)
+		}
+	}
+
+	{
+		testID := 1
+		t.Logf("\tTest %d:\tWhen handling an empty query string.", testID)
+		{
+			q, err := web.ParseQuery(httptest.NewRequest("GET", "/v1/users", nil), queryConfig)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to parse the query : %v.", failed, testID, err)
+			}
+
+			if q.Page != 1 || q.RowsPerPage != 10 || q.OrderBy != queryConfig.DefaultOrder {
+				t.Fatalf("\t%s\tTest %d:\tShould get the defaults : %+v.", failed, testID, q)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the defaults.", success, testID)
+		}
+	}
+
+	{
+		testID := 2
+		t.Logf("\tTest %d:\tWhen handling a bad query string.", testID)
+		{
+			r := httptest.NewRequest("GET", "/v1/users?page=0&rows=500&orderBy=password,UP", nil)
+
+			_, err := web.ParseQuery(r, queryConfig)
+			if !validate.IsFieldErrors(err) {
+				t.Fatalf("\t%s\tTest %d:\tShould get back field errors : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back field errors.", success, testID)
+
+			fields := validate.GetFieldErrors(err).Fields()
+			for _, field := range []string{"page", "rows", "orderBy"} {
+				if _, exists := fields[field]; !exists {
+					t.Fatalf("\t%s\tTest %d:\tShould have an error for field %q : %v.", failed, testID, field, fields)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould have an error for every bad field.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/dimfeld/httptreemux"
+)
+
+// maxBodyBytes limits the size of a request body that will be decoded. This
+// should be reasonable for any JSON document the API accepts and prevents a
+// client from tying up the service with a huge payload.
+const maxBodyBytes = 1 << 20
+
+// Param returns the web call parameters from the request.
+func Param(r *http.Request, key string) string {
+	m := httptreemux.ContextParams(r.Context())
+	return m[key]
+}
+
+// Decode reads the body of an HTTP request looking for a JSON document. The
+// body is decoded into the provided value and then checked against any
+// validate tags declared on the model.
+//
+// Validation failures are returned as validate.FieldErrors, problems with the
+// request itself are returned as validate.RequestError.
+func Decode(r *http.Request, val any) error {
+	ct := r.Header.Get("Content-Type")
+	if mt, _, err := mime.ParseMediaType(ct); err != nil || mt != "application/json" {
+		err := fmt.Errorf("unsupported content type %q, expecting application/json", ct)
+		return validate.NewRequestError(err, http.StatusUnsupportedMediaType)
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(val); err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			err := fmt.Errorf("request body must not be larger than %d bytes", mbe.Limit)
+			return validate.NewRequestError(err, http.StatusRequestEntityTooLarge)
+		}
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	if decoder.More() {
+		err := errors.New("request body must only contain a single JSON document")
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	if err := validate.Check(val); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/internal/platform/web"
+)
+
+type payload struct {
+	Name  string `json:"name" validate:"required"`
+	Email string `json:"email" validate:"required,email"`
+}
+
+func Test_Decode(t *testing.T) {
+	t.Log("Given the need to decode and validate request bodies.")
+
+	tt := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		fields      []string
+	}{
+		{"valid", "application/json; charset=utf-8", `{"name":"Bill","email":"bill@ardanlabs.com"}`, 0, nil},
+		{"content type", "text/plain", `{"name":"Bill","email":"bill@ardanlabs.com"}`, http.StatusUnsupportedMediaType, nil},
+		{"unknown field", "application/json", `{"name":"Bill","email":"bill@ardanlabs.com","admin":true}`, http.StatusBadRequest, nil},
+		{"too large", "application/json", `{"name":"` + strings.Repeat("x", 1<<20) + `"}`, http.StatusRequestEntityTooLarge, nil},
+		{"invalid", "application/json", `{"email":"bill"}`, 0, []string{"name", "email"}},
+	}
+
+	for testID, tst := range tt {
+		t.Logf("\tTest %d:\tWhen handling a %s body.", testID, tst.name)
+		{
+			r := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(tst.body))
+			r.Header.Set("Content-Type", tst.contentType)
+
+			var p payload
+			err := web.Decode(r, &p)
+
+			switch {
+			case tst.status != 0:
+				re := validate.GetRequestError(err)
+				if re == nil || re.Status != tst.status {
+					t.Fatalf("\t%s\tTest %d:\tShould get a %d request error : %v.", failed, testID, tst.status, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould get a %d request error.", success, testID, tst.status)
+
+			case tst.fields != nil:
+				fields := validate.GetFieldErrors(err).Fields()
+				for _, field := range tst.fields {
+					if _, exists := fields[field]; !exists {
+						t.Fatalf("\t%s\tTest %d:\tShould have an error for field %q : %v.", failed, testID, field, err)
+					}
+				}
+				t.Logf("\t%s\tTest %d:\tShould have an error for each bad field.", success, testID)
+
+			default:
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to decode the body : %v.", failed, testID, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be able to decode the body.", success, testID)
+			}
+		}
+	}
+}
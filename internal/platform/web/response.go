@@ -1,10 +1,13 @@
 package web
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
-	"go.opentelemetry.io/otel/attribute"
+	"fmt"
 	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 //var (
@@ -40,28 +43,90 @@ import (
 //	Respond(ctx, w, JSONError{Error: err.Error()}, code)
 //}
 
-
-// Respond converts a Go value to JSON and sends it to the client.
+// Respond encodes a Go value in the format negotiated with the Accept header
+// of the request and sends it to the client. When no registered format is
+// accepted a 406 request error is returned, except for error responses
+// which fall back to JSON so the client still learns what went wrong.
+// Responses without a body are sent as is, and nothing is written once the
+// request was upgraded to a WebSocket.
 func Respond(ctx context.Context, w http.ResponseWriter, data any, statusCode int) error {
+	if upgraded(ctx) {
+		return nil
+	}
+
+	if !BodyAllowed(statusCode) {
+		return respond(ctx, w, nil, statusCode, "", nil)
+	}
+
+	accept := GetValues(ctx).Accept
+
+	mediaType, enc, ok := negotiate(accept)
+	if !ok {
+		if statusCode < http.StatusBadRequest {
+			return notAcceptable(accept)
+		}
+		mediaType, enc = MediaTypeJSON, encodeJSON
+	}
+	w.Header().Add("Vary", "Accept")
+
+	return respond(ctx, w, data, statusCode, mediaType, enc)
+}
+
+// RespondAs sends the value to the client in the format of the media type,
+// regardless of the Accept header, so it never fails with a 406. The media
+// type must have a registered encoder. Nothing is written once the request
+// was upgraded to a WebSocket.
+func RespondAs(ctx context.Context, w http.ResponseWriter, data any, statusCode int, mediaType string) error {
+	if upgraded(ctx) {
+		return nil
+	}
+
+	enc, ok := lookupEncoder(mediaType)
+	if !ok {
+		return fmt.Errorf("no encoder registered for media type %q", mediaType)
+	}
+
+	return respond(ctx, w, data, statusCode, mediaType, enc)
+}
+
+// upgraded reports whether the connection was taken over by a WebSocket, so
+// there is no response left to write.
+func upgraded(ctx context.Context) bool {
+	return GetValues(ctx).StatusCode == http.StatusSwitchingProtocols
+}
+
+// BodyAllowed reports whether a response with the status may have a body.
+func BodyAllowed(statusCode int) bool {
+	return statusCode != http.StatusNoContent && statusCode != http.StatusNotModified
+}
+
+// respond encodes the value and writes the response.
+func respond(ctx context.Context, w http.ResponseWriter, data any, statusCode int, mediaType string, enc Encoder) error {
 	ctx, span := AddSpan(ctx, "foundation.web.response", attribute.Int("status", statusCode))
 	defer span.End()
 
 	SetStatusCode(ctx, statusCode)
 
-	if statusCode == http.StatusNoContent {
+	if !BodyAllowed(statusCode) {
 		w.WriteHeader(statusCode)
 		return nil
 	}
 
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return err
+	// Encode before writing anything so a failure can still be reported to
+	// the client.
+	var buf bytes.Buffer
+	if err := enc(&buf, data); err != nil {
+		return fmt.Errorf("encoding %s: %w", mediaType, err)
+	}
+
+	if strings.HasPrefix(mediaType, "text/") {
+		mediaType += "; charset=utf-8"
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", mediaType)
 	w.WriteHeader(statusCode)
 
-	if _, err := w.Write(jsonData); err != nil {
+	if _, err := w.Write(buf.Bytes()); err != nil {
 		return err
 	}
 
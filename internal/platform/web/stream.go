@@ -0,0 +1,236 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Set of media types used for streaming responses.
+const (
+	MediaTypeEventStream = "text/event-stream"
+	MediaTypeNDJSON      = "application/x-ndjson"
+)
+
+// Event is a single message of a Server-Sent Events stream. Data is written
+// as is when it's a string or byte slice and as JSON otherwise.
+type Event struct {
+	ID    string
+	Name  string
+	Data  any
+	Retry time.Duration
+}
+
+// StreamConfig declares how a stream behaves while the client is connected.
+type StreamConfig struct {
+
+	// Heartbeat is how often something is written when there is nothing to
+	// send, so proxies keep the connection open and a client that went away
+	// is noticed. Zero disables heartbeats.
+	Heartbeat time.Duration
+
+	// WriteTimeout limits how long a single write may take. It replaces the
+	// server's write timeout, which would otherwise end the stream. Zero
+	// allows each write until a margin after the next heartbeat is due, or
+	// removes the deadline when heartbeats are disabled.
+	WriteTimeout time.Duration
+}
+
+// streamWriteMargin is how long a write may take once the next heartbeat is
+// due when no write timeout is configured.
+const streamWriteMargin = 10 * time.Second
+
+// LastEventID returns the id of the last event the client received before
+// it reconnected, so the stream can resume after it. Browsers send it in the
+// Last-Event-ID header, polyfills often use a query parameter instead.
+func LastEventID(r *http.Request) string {
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		return id
+	}
+	return r.URL.Query().Get("lastEventId")
+}
+
+// StreamEvents sends the events to the client as Server-Sent Events until the
+// channel is closed or the request is canceled. Each event is flushed as it
+// is written. A client that disconnects ends the stream without an error so
+// it isn't reported as a failure of the service.
+func StreamEvents(ctx context.Context, w http.ResponseWriter, events <-chan Event, cfg StreamConfig) error {
+	s, err := newStreamer(ctx, w, MediaTypeEventStream, cfg)
+	if err != nil {
+		return err
+	}
+
+	encode := func(buf *bytes.Buffer, e Event) error {
+		return writeEvent(buf, e)
+	}
+
+	heartbeat := []byte(": heartbeat\n\n")
+
+	return stream(ctx, s, events, encode, heartbeat)
+}
+
+// StreamNDJSON sends the items to the client as newline delimited JSON until
+// the channel is closed or the request is canceled. Each item is flushed as
+// it is written. A heartbeat is an empty line, which NDJSON readers skip. A
+// client that disconnects ends the stream without an error so it isn't
+// reported as a failure of the service.
+func StreamNDJSON[T any](ctx context.Context, w http.ResponseWriter, items <-chan T, cfg StreamConfig) error {
+	s, err := newStreamer(ctx, w, MediaTypeNDJSON, cfg)
+	if err != nil {
+		return err
+	}
+
+	encode := func(buf *bytes.Buffer, item T) error {
+		return json.NewEncoder(buf).Encode(item)
+	}
+
+	return stream(ctx, s, items, encode, []byte("\n"))
+}
+
+// =============================================================================
+
+// streamer writes and flushes the parts of a streaming response.
+type streamer struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	rc      *http.ResponseController
+	cfg     StreamConfig
+}
+
+// newStreamer checks the response can be streamed and writes the headers.
+func newStreamer(ctx context.Context, w http.ResponseWriter, mediaType string, cfg StreamConfig) (*streamer, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("streaming is not supported by the response writer")
+	}
+
+	s := streamer{
+		w:       w,
+		flusher: flusher,
+		rc:      http.NewResponseController(w),
+		cfg:     cfg,
+	}
+	s.extendDeadline()
+
+	SetStatusCode(ctx, http.StatusOK)
+
+	h := w.Header()
+	h.Set("Content-Type", mediaType)
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &s, nil
+}
+
+// write sends the data to the client right away.
+func (s *streamer) write(data []byte) error {
+	s.extendDeadline()
+
+	if _, err := s.w.Write(data); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+
+	return nil
+}
+
+// extendDeadline moves the write deadline of the connection forward. The
+// response controller finds the connection through the writers middleware
+// wrapped around it, as long as they provide an Unwrap method.
+func (s *streamer) extendDeadline() {
+	var deadline time.Time
+	switch {
+	case s.cfg.WriteTimeout > 0:
+		deadline = time.Now().Add(s.cfg.WriteTimeout)
+	case s.cfg.Heartbeat > 0:
+		deadline = time.Now().Add(s.cfg.Heartbeat + streamWriteMargin)
+	}
+
+	// A zero deadline means the writes never time out.
+	s.rc.SetWriteDeadline(deadline)
+}
+
+// stream writes each value from the channel until it's closed, the request
+// is canceled or a write fails because the client went away.
+func stream[T any](ctx context.Context, s *streamer, values <-chan T, encode func(*bytes.Buffer, T) error, heartbeat []byte) error {
+	var tick <-chan time.Time
+	if s.cfg.Heartbeat > 0 {
+		ticker := time.NewTicker(s.cfg.Heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var buf bytes.Buffer
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-tick:
+			if err := s.write(heartbeat); err != nil {
+				return nil
+			}
+
+		case v, ok := <-values:
+			if !ok {
+				return nil
+			}
+
+			buf.Reset()
+			if err := encode(&buf, v); err != nil {
+				return err
+			}
+
+			if err := s.write(buf.Bytes()); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// writeEvent formats the event in the text/event-stream format.
+func writeEvent(buf *bytes.Buffer, e Event) error {
+	if e.ID != "" {
+		buf.WriteString("id: " + singleLine(e.ID) + "\n")
+	}
+	if e.Name != "" {
+		buf.WriteString("event: " + singleLine(e.Name) + "\n")
+	}
+	if e.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+
+	var data string
+	switch v := e.Data.(type) {
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		jsonData, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		data = string(jsonData)
+	}
+
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+
+	return nil
+}
+
+// singleLine removes the line breaks that would end a field early.
+func singleLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
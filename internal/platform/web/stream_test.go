@@ -0,0 +1,202 @@
+package web_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/internal/platform/web"
+)
+
+func Test_Stream(t *testing.T) {
+	t.Log("Given the need to stream updates to clients.")
+
+	done := make(chan error, 1)
+
+	app := web.New(nil, nil)
+	app.Handle(http.MethodGet, "", "/events", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		start := 0
+		if id := web.LastEventID(r); id != "" {
+			start, _ = strconv.Atoi(id)
+		}
+
+		events := make(chan web.Event)
+		go func() {
+			defer close(events)
+			for i := start + 1; i <= start+2; i++ {
+				select {
+				case events <- web.Event{ID: strconv.Itoa(i), Name: "sale", Data: map[string]int{"total": i}}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			events <- web.Event{Data: "line one\nline two"}
+		}()
+
+		return web.StreamEvents(ctx, w, events, web.StreamConfig{Heartbeat: time.Hour})
+	})
+	app.Handle(http.MethodGet, "", "/ndjson", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		items := make(chan map[string]int)
+		go func() {
+			items <- map[string]int{"total": 1}
+			items <- map[string]int{"total": 2}
+		}()
+
+		err := web.StreamNDJSON(ctx, w, items, web.StreamConfig{Heartbeat: 10 * time.Millisecond, WriteTimeout: time.Second})
+		done <- err
+		return err
+	})
+
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen resuming an event stream.", testID)
+		{
+			req, _ := http.NewRequest(http.MethodGet, srv.URL+"/events", nil)
+			req.Header.Set("Last-Event-ID", "5")
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to connect : %v.", failed, testID, err)
+			}
+			defer resp.Body.Close()
+
+			if ct := resp.Header.Get("Content-Type"); ct != web.MediaTypeEventStream {
+				t.Fatalf("\t%s\tTest %d:\tShould respond with an event stream : got %s.", failed, testID, ct)
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to read the stream : %v.", failed, testID, err)
+			}
+
+			exp := "id: 6\nevent: sale\ndata: {\"total\":6}\n\n" +
+				"id: 7\nevent: sale\ndata: {\"total\":7}\n\n" +
+				"data: line one\ndata: line two\n\n"
+			if string(body) != exp {
+				t.Fatalf("\t%s\tTest %d:\tShould resume after the last event id : got %q.", failed, testID, body)
+			}
+			t.Logf("\t%s\tTest %d:\tShould resume after the last event id.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen a client disconnects from an NDJSON stream.", testID)
+		{
+			ctx, cancel := context.WithCancel(context.Background())
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/ndjson", nil)
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to connect : %v.", failed, testID, err)
+			}
+
+			sc := bufio.NewScanner(resp.Body)
+			var lines []string
+			for len(lines) < 2 && sc.Scan() {
+				if sc.Text() != "" {
+					lines = append(lines, sc.Text())
+				}
+			}
+			if strings.Join(lines, ",") != `{"total":1},{"total":2}` {
+				t.Fatalf("\t%s\tTest %d:\tShould receive each item as it is sent : got %v.", failed, testID, lines)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive each item as it is sent.", success, testID)
+
+			cancel()
+			resp.Body.Close()
+
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould end the stream without an error : %v.", failed, testID, err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("\t%s\tTest %d:\tShould end the stream when the client disconnects.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould end the stream without an error.", success, testID)
+		}
+	}
+}
+
+func Test_StreamDeadline(t *testing.T) {
+	t.Log("Given the need to stream for longer than the server's write timeout.")
+
+	// wrap mimics middleware that wraps the response writer, it can only be
+	// seen through with Unwrap.
+	wrap := func(handler web.Handler) web.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			return handler(ctx, &wrappedWriter{ResponseWriter: w}, r)
+		}
+	}
+
+	tt := []struct {
+		name string
+		cfg  web.StreamConfig
+	}{
+		{"a write timeout", web.StreamConfig{WriteTimeout: time.Second}},
+		{"heartbeats and the default write timeout", web.StreamConfig{Heartbeat: 60 * time.Millisecond}},
+		{"the default config", web.StreamConfig{}},
+	}
+
+	for testID, tst := range tt {
+		t.Logf("\tTest %d:\tWhen the items arrive after the write timeout with %s.", testID, tst.name)
+		{
+			app := web.New(nil, nil, wrap)
+			app.Handle(http.MethodGet, "", "/slow", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				items := make(chan int)
+				go func() {
+					defer close(items)
+					for i := 1; i <= 3; i++ {
+						time.Sleep(150 * time.Millisecond)
+						items <- i
+					}
+				}()
+
+				return web.StreamNDJSON(ctx, w, items, tst.cfg)
+			})
+
+			srv := httptest.NewUnstartedServer(app)
+			srv.Config.WriteTimeout = 100 * time.Millisecond
+			srv.Start()
+			defer srv.Close()
+
+			resp, err := http.Get(srv.URL + "/slow")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to connect : %v.", failed, testID, err)
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to read the stream : %v.", failed, testID, err)
+			}
+
+			// Heartbeats are empty lines.
+			if items := strings.Fields(string(body)); strings.Join(items, ",") != "1,2,3" {
+				t.Fatalf("\t%s\tTest %d:\tShould receive every item : got %q.", failed, testID, body)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive every item.", success, testID)
+		}
+	}
+}
+
+// wrappedWriter hides the response writer it wraps from type assertions.
+type wrappedWriter struct {
+	http.ResponseWriter
+}
+
+func (w *wrappedWriter) Flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *wrappedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -2,14 +2,19 @@ package web
 
 import (
 	"context"
-	"github.com/pkg/errors"
+	"crypto/rand"
+	"encoding/hex"
 	"net/http"
 	"os"
 	"syscall"
 	"time"
 
 	"github.com/dimfeld/httptreemux"
-	"github.com/pborman/uuid"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // TraceIDHeader is the header added to outgoing requests which adds the
@@ -19,6 +24,10 @@ const TraceIDHeader = "X-Trace-ID"
 // KeyValues is how request values or stored/retrieved.
 const KeyValues ctxKey = 1
 
+// propagator reads the W3C traceparent and tracestate headers so a request
+// joins the trace of the caller.
+var propagator = propagation.TraceContext{}
+
 // A Handler is a type that handles an http request within our own little mini
 // framework.
 type Handler func(ctx context.Context, w http.ResponseWriter, r *http.Request) error
@@ -29,28 +38,38 @@ type Handler func(ctx context.Context, w http.ResponseWriter, r *http.Request) e
 type App struct {
 	*httptreemux.ContextMux
 	shutdown chan os.Signal
-	mw []Middleware
+	tracer   trace.Tracer
+	mw       []Middleware
+	sockets  *socketSet
 }
 
 // New creates an App value that handle a set of routes for the application.
-func New(shutdown chan os.Signal, mw ...Middleware) *App {
+// Every request is traced as a server span using the tracer. A nil tracer
+// disables tracing.
+func New(shutdown chan os.Signal, tracer trace.Tracer, mw ...Middleware) *App {
+	if tracer == nil {
+		tracer = trace.NewNoopTracerProvider().Tracer("")
+	}
+
 	return &App{
 		ContextMux: httptreemux.NewContextMux(),
-		shutdown: shutdown,
-		mw: mw,
-
+		shutdown:   shutdown,
+		tracer:     tracer,
+		mw:         mw,
+		sockets:    newSocketSet(),
 	}
 }
 
 // SignalShutdown is used to gracefully shut down the app when an integrity
-// issue is identified.
+// issue is identified. Open WebSockets are sent a close frame right away.
 func (a *App) SignalShutdown() {
+	a.sockets.shutdown()
 	a.shutdown <- syscall.SIGTERM
 }
 
 // Handle is our mechanism for mounting Handlers for a given HTTP verb and path
 // pair, this makes for really easy, convenient routing.
-//func (a *App) Handle(verb, path string, handler Handler) {
+// func (a *App) Handle(verb, path string, handler Handler) {
 func (a *App) Handle(method string, group string, path string, handler Handler, mw ...Middleware) {
 	// Wrap up the application-wide first, this will call the first function
 	// of each middleware which will return a function of type Handler.
@@ -58,26 +77,55 @@ func (a *App) Handle(method string, group string, path string, handler Handler,
 	//Add the application's general middleware to the handler chain
 	handler = wrapMiddleware(handler, a.mw)
 
+	finalPath := path
+	if group != "" {
+		finalPath = "/" + group + path
+	}
 
 	// The function to execute for each request.
 	h := func(w http.ResponseWriter, r *http.Request) {
 
+		// Start the server span for the request, continuing the trace of the
+		// caller when a traceparent header was provided.
+		ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := a.tracer.Start(ctx, method+" "+finalPath,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.method", method),
+				attribute.String("http.target", r.URL.RequestURI()),
+				attribute.String("http.route", finalPath),
+			),
+		)
+		defer span.End()
+
 		// Set the context with the required values to
 		// process the request.
 		v := Values{
-			TraceID: uuid.New(),
+			TraceID: traceID(span.SpanContext()),
+			Tracer:  a.tracer,
+			Route:   finalPath,
+			Accept:  r.Header.Get("Accept"),
 			Now:     time.Now(),
 		}
-		ctx := context.WithValue(r.Context(), KeyValues, &v)
+		ctx = context.WithValue(ctx, KeyValues, &v)
 
 		// Set the trace id on the outgoing requests before any other header to
 		// ensure that the trace id is ALWAYS added to the request regardless of
 		// any error occuring or not.
 		w.Header().Set(TraceIDHeader, v.TraceID)
 
-		//// Call the wrapped handler functions.
+		// Call the wrapped handler functions.
+		err := handler(ctx, w, r)
+
+		span.SetAttributes(attribute.Int("http.status_code", v.StatusCode))
+		if v.StatusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(v.StatusCode))
+		}
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
 
-		if err := handler(ctx, w, r); err != nil {
 			if validateShutdown(err) {
 				a.SignalShutdown()
 				return
@@ -85,11 +133,6 @@ func (a *App) Handle(method string, group string, path string, handler Handler,
 		}
 	}
 
-	finalPath := path
-	if group != "" {
-		finalPath = "/" + group + path
-	}
-
 	// Add this handler for the specified verb and route.
 	a.ContextMux.Handle(method, finalPath, h)
 }
@@ -133,3 +176,16 @@ func validateShutdown(err error) bool {
 
 	return true
 }
+
+// traceID returns the trace id of the span. When tracing is disabled the span
+// context is invalid, so a random id is generated to still correlate the
+// logs of a request.
+func traceID(sc trace.SpanContext) string {
+	if sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+
+	var id trace.TraceID
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
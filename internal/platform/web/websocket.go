@@ -0,0 +1,587 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ardanlabs/service/business/sys/validate"
+)
+
+// websocketGUID is appended to the key of the client to compute the accept
+// value of the handshake as described in RFC 6455 section 4.2.2.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Defaults for the zero values of WebSocketConfig.
+const (
+	defaultReadLimit    = 32 << 10
+	defaultWriteTimeout = 10 * time.Second
+	defaultCloseTimeout = 5 * time.Second
+)
+
+// ErrWebSocketClosed is returned when a message is written after the close
+// handshake started or read after the connection ended.
+var ErrWebSocketClosed = errors.New("websocket closed")
+
+// WebSocketHandler handles an upgraded connection. The context is canceled
+// when the application shuts down. The connection is closed when the handler
+// returns and must not be used after that.
+type WebSocketHandler func(ctx context.Context, ws *WebSocket) error
+
+// WebSocketConfig declares how the connections of a WebSocket route behave.
+type WebSocketConfig struct {
+
+	// Subprotocols lists the subprotocols the route speaks, in order of
+	// preference. The first one the client asks for is selected.
+	Subprotocols []string
+
+	// CheckOrigin reports whether a browser on the origin of the request may
+	// connect. Nil allows requests without an Origin header and those where
+	// the origin matches the host of the request.
+	CheckOrigin func(r *http.Request) bool
+
+	// ReadLimit is the size in bytes of the largest message accepted. A
+	// larger message closes the connection. Zero uses 32 KiB.
+	ReadLimit int64
+
+	// WriteTimeout limits how long a single write may take. Zero uses 10s.
+	WriteTimeout time.Duration
+
+	// PingInterval is how often a ping is sent to the client. Zero disables
+	// pings.
+	PingInterval time.Duration
+
+	// IdleTimeout closes the connection when nothing, including a pong, was
+	// received for this long. Zero never times out.
+	IdleTimeout time.Duration
+
+	// CloseTimeout is how long the client has to answer a close frame before
+	// the connection is dropped. Zero uses 5s.
+	CloseTimeout time.Duration
+}
+
+// withDefaults replaces the zero values with their defaults.
+func (cfg WebSocketConfig) withDefaults() WebSocketConfig {
+	if cfg.CheckOrigin == nil {
+		cfg.CheckOrigin = sameOrigin
+	}
+	if cfg.ReadLimit <= 0 {
+		cfg.ReadLimit = defaultReadLimit
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = defaultWriteTimeout
+	}
+	if cfg.CloseTimeout <= 0 {
+		cfg.CloseTimeout = defaultCloseTimeout
+	}
+	return cfg
+}
+
+// WebSocket returns a handler that upgrades the request to a WebSocket and
+// runs the WebSocket handler for the connection. It's mounted with Handle
+// like any other handler, so the middleware of the route runs before the
+// upgrade. Once upgraded, the status of the request is 101 and errors of the
+// handler are still returned to the middleware, but no response is written.
+func (a *App) WebSocket(handler WebSocketHandler, cfg WebSocketConfig) Handler {
+	cfg = cfg.withDefaults()
+
+	h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		subprotocol, err := checkHandshake(w, r, cfg)
+		if err != nil {
+			return err
+		}
+
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			return errors.New("websocket upgrade is not supported by the response writer")
+		}
+
+		if a.sockets.isClosing() {
+			return validate.NewRequestError(errors.New("service is shutting down"), http.StatusServiceUnavailable)
+		}
+
+		conn, brw, err := hijacker.Hijack()
+		if err != nil {
+			return fmt.Errorf("hijacking connection: %w", err)
+		}
+
+		// The server set deadlines for the request on the connection, they
+		// don't apply to a connection that lives on its own.
+		conn.SetDeadline(time.Time{})
+
+		if err := writeHandshake(conn, w.Header(), r.Header.Get("Sec-WebSocket-Key"), subprotocol, cfg.WriteTimeout); err != nil {
+			conn.Close()
+			return nil
+		}
+		SetStatusCode(ctx, http.StatusSwitchingProtocols)
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		ws := newWebSocket(conn, brw.Reader, cfg, subprotocol, cancel)
+		if !a.sockets.add(ws) {
+			ws.finish(CloseGoingAway)
+			return nil
+		}
+		defer a.sockets.remove(ws)
+
+		if cfg.PingInterval > 0 {
+			go ws.ping(ctx)
+		}
+
+		err = handler(ctx, ws)
+		cancel()
+
+		if err != nil && !clientGone(err) {
+			ws.finish(CloseInternalServerError)
+			return err
+		}
+
+		ws.finish(CloseNormalClosure)
+		return nil
+	}
+
+	return h
+}
+
+// CloseWebSockets starts the close handshake of every open WebSocket with
+// the going away status and waits for their handlers to return. Connections
+// still open when the context is done are dropped. New upgrades are refused
+// from now on.
+func (a *App) CloseWebSockets(ctx context.Context) error {
+	return a.sockets.closeAll(ctx)
+}
+
+// checkHandshake validates the opening handshake of the client and returns
+// the selected subprotocol.
+func checkHandshake(w http.ResponseWriter, r *http.Request, cfg WebSocketConfig) (string, error) {
+	if r.Method != http.MethodGet {
+		return "", validate.NewRequestError(errors.New("websocket upgrade requires GET"), http.StatusMethodNotAllowed)
+	}
+
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return "", validate.NewRequestError(errors.New("websocket upgrade required"), http.StatusBadRequest)
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return "", validate.NewRequestError(errors.New("unsupported websocket version"), http.StatusUpgradeRequired)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(r.Header.Get("Sec-WebSocket-Key"))
+	if err != nil || len(key) != 16 {
+		return "", validate.NewRequestError(errors.New("invalid websocket key"), http.StatusBadRequest)
+	}
+
+	if !cfg.CheckOrigin(r) {
+		return "", validate.NewRequestError(errors.New("origin not allowed"), http.StatusForbidden)
+	}
+
+	for _, requested := range headerTokens(r.Header, "Sec-WebSocket-Protocol") {
+		for _, supported := range cfg.Subprotocols {
+			if requested == supported {
+				return supported, nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// writeHandshake sends the response that completes the upgrade. The headers
+// already set by the middleware, like the trace id, are sent along.
+func writeHandshake(conn net.Conn, header http.Header, key string, subprotocol string, timeout time.Duration) error {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+
+	h := header.Clone()
+	h.Set("Upgrade", "websocket")
+	h.Set("Connection", "Upgrade")
+	h.Set("Sec-WebSocket-Accept", base64.StdEncoding.EncodeToString(sum[:]))
+	if subprotocol != "" {
+		h.Set("Sec-WebSocket-Protocol", subprotocol)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	h.Write(&buf)
+	buf.WriteString("\r\n")
+
+	conn.SetWriteDeadline(time.Now().Add(timeout))
+	_, err := conn.Write(buf.Bytes())
+	return err
+}
+
+// sameOrigin allows requests that don't come from a browser and those where
+// the origin matches the host of the request.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	i := strings.Index(origin, "://")
+	if i < 0 {
+		return false
+	}
+	return strings.EqualFold(origin[i+3:], r.Host)
+}
+
+// headerTokens returns the comma separated tokens of every value of the
+// header.
+func headerTokens(h http.Header, name string) []string {
+	var tokens []string
+	for _, value := range h.Values(name) {
+		for _, token := range strings.Split(value, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
+
+// headerContains reports whether the header has the token, ignoring case.
+func headerContains(h http.Header, name string, token string) bool {
+	for _, t := range headerTokens(h, name) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientGone reports whether the error means the client ended the
+// connection, which isn't a failure of the handler.
+func clientGone(err error) bool {
+	var ce *CloseError
+	if errors.As(err, &ce) {
+		switch ce.Code {
+		case CloseNormalClosure, CloseGoingAway, CloseNoStatusReceived:
+			return true
+		}
+		return false
+	}
+
+	return errors.Is(err, ErrWebSocketClosed) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed)
+}
+
+// =============================================================================
+
+// MessageType identifies the kind of data a message carries.
+type MessageType int
+
+// Set of message types. The values match the opcodes of the frames.
+const (
+	TextMessage   MessageType = opText
+	BinaryMessage MessageType = opBinary
+)
+
+// WebSocket is an upgraded connection. Messages may be written from many
+// goroutines at once, but only one goroutine may read at a time.
+type WebSocket struct {
+	conn        net.Conn
+	br          io.Reader
+	cfg         WebSocketConfig
+	subprotocol string
+	cancel      context.CancelFunc
+
+	writeMu   sync.Mutex
+	closeSent bool
+
+	// Only touched by the reading goroutine. The handshake is over when the
+	// close frame of the client arrived or the connection failed.
+	closeReceived bool
+	failed        bool
+}
+
+// newWebSocket constructs a WebSocket for the upgraded connection.
+func newWebSocket(conn net.Conn, br io.Reader, cfg WebSocketConfig, subprotocol string, cancel context.CancelFunc) *WebSocket {
+	ws := WebSocket{
+		conn:        conn,
+		br:          br,
+		cfg:         cfg,
+		subprotocol: subprotocol,
+		cancel:      cancel,
+	}
+	return &ws
+}
+
+// Subprotocol returns the subprotocol selected during the handshake, if any.
+func (ws *WebSocket) Subprotocol() string {
+	return ws.subprotocol
+}
+
+// RemoteAddr returns the network address of the client.
+func (ws *WebSocket) RemoteAddr() net.Addr {
+	return ws.conn.RemoteAddr()
+}
+
+// ReadMessage returns the next message from the client. Pings are answered
+// and fragmented messages are put back together while reading. A close frame
+// from the client is answered and returned as a *CloseError.
+func (ws *WebSocket) ReadMessage() (MessageType, []byte, error) {
+	if ws.closeReceived || ws.failed {
+		return 0, nil, ErrWebSocketClosed
+	}
+
+	var typ MessageType
+	var msg []byte
+
+	for {
+		f, err := ws.readFrame(ws.cfg.ReadLimit - int64(len(msg)))
+		if err != nil {
+			return 0, nil, ws.readFailed(err)
+		}
+
+		switch f.opcode {
+		case opPing:
+			if err := ws.writeFrame(opPong, f.payload); err != nil && !errors.Is(err, ErrWebSocketClosed) {
+				return 0, nil, ws.readFailed(err)
+			}
+			continue
+
+		case opPong:
+			continue
+
+		case opClose:
+			ce, err := parseClose(f.payload)
+			if err != nil {
+				return 0, nil, ws.readFailed(err)
+			}
+			ws.closeReceived = true
+			ws.sendClose(ce.Code, "")
+			return 0, nil, ce
+
+		case opContinuation:
+			if typ == 0 {
+				return 0, nil, ws.readFailed(protocolError("continuation frame without a message"))
+			}
+
+		case opText, opBinary:
+			if typ != 0 {
+				return 0, nil, ws.readFailed(protocolError("new message before the last one finished"))
+			}
+			typ = MessageType(f.opcode)
+
+		default:
+			return 0, nil, ws.readFailed(protocolError(fmt.Sprintf("unknown opcode %d", f.opcode)))
+		}
+
+		msg = append(msg, f.payload...)
+		if !f.fin {
+			continue
+		}
+
+		if typ == TextMessage && !utf8.Valid(msg) {
+			return 0, nil, ws.readFailed(&CloseError{Code: CloseInvalidFramePayloadData, Reason: "text message is not valid utf-8"})
+		}
+
+		return typ, msg, nil
+	}
+}
+
+// ReadJSON reads the next message and decodes it as JSON into the value.
+func (ws *WebSocket) ReadJSON(v any) error {
+	_, data, err := ws.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteMessage sends the data to the client as a single message.
+func (ws *WebSocket) WriteMessage(typ MessageType, data []byte) error {
+	if typ != TextMessage && typ != BinaryMessage {
+		return fmt.Errorf("unknown message type %d", typ)
+	}
+	return ws.writeFrame(byte(typ), data)
+}
+
+// WriteJSON encodes the value as JSON and sends it as a text message.
+func (ws *WebSocket) WriteJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ws.writeFrame(opText, data)
+}
+
+// Close starts the close handshake with the status code and reason. Writes
+// fail from now on, and the answer of the client is returned by the next
+// read. The handler returns as usual once it sees the answer.
+func (ws *WebSocket) Close(code int, reason string) error {
+	return ws.sendClose(code, reason)
+}
+
+// sendClose writes the close frame unless one was already sent and gives
+// the client the close timeout to answer.
+func (ws *WebSocket) sendClose(code int, reason string) error {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+
+	if ws.closeSent {
+		return nil
+	}
+	ws.closeSent = true
+
+	ws.conn.SetReadDeadline(time.Now().Add(ws.cfg.CloseTimeout))
+
+	return ws.writeFrameLocked(opClose, closePayload(code, reason))
+}
+
+// shutdown is called when the application shuts down. The handler learns
+// about it through its context and the answer of the client.
+func (ws *WebSocket) shutdown() {
+	ws.cancel()
+	ws.sendClose(CloseGoingAway, "server shutting down")
+}
+
+// finish completes the close handshake after the handler returned, waiting
+// for the client to answer, and closes the connection.
+func (ws *WebSocket) finish(code int) {
+	defer ws.conn.Close()
+
+	if ws.failed {
+		return
+	}
+
+	if err := ws.sendClose(code, ""); err != nil {
+		return
+	}
+
+	for !ws.closeReceived && !ws.failed {
+		ws.ReadMessage()
+	}
+}
+
+// ping sends a ping every interval until the context is canceled or a
+// write fails.
+func (ws *WebSocket) ping(ctx context.Context) {
+	ticker := time.NewTicker(ws.cfg.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ws.writeFrame(opPing, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readFailed ends the connection after a read error. Protocol violations
+// are reported to the client with a close frame first.
+func (ws *WebSocket) readFailed(err error) error {
+	ws.failed = true
+
+	var ce *CloseError
+	if errors.As(err, &ce) {
+		ws.sendClose(ce.Code, ce.Reason)
+	}
+
+	return err
+}
+
+// =============================================================================
+
+// socketSet tracks the open WebSockets of an application so they can be
+// closed when it shuts down.
+type socketSet struct {
+	mu      sync.Mutex
+	sockets map[*WebSocket]struct{}
+	closing bool
+	wg      sync.WaitGroup
+}
+
+// newSocketSet constructs an empty set.
+func newSocketSet() *socketSet {
+	return &socketSet{
+		sockets: make(map[*WebSocket]struct{}),
+	}
+}
+
+// add tracks the WebSocket. It returns false when the set is closing.
+func (s *socketSet) add(ws *WebSocket) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closing {
+		return false
+	}
+
+	s.sockets[ws] = struct{}{}
+	s.wg.Add(1)
+
+	return true
+}
+
+// remove stops tracking the WebSocket once its handler returned.
+func (s *socketSet) remove(ws *WebSocket) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sockets, ws)
+	s.wg.Done()
+}
+
+// isClosing reports whether the set refuses new WebSockets.
+func (s *socketSet) isClosing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closing
+}
+
+// shutdown refuses new WebSockets and starts the close handshake of the
+// open ones without waiting for them.
+func (s *socketSet) shutdown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closing = true
+	for ws := range s.sockets {
+		go ws.shutdown()
+	}
+}
+
+// closeAll shuts down every WebSocket and waits for their handlers to
+// return or the context to be done.
+func (s *socketSet) closeAll(ctx context.Context) error {
+	s.shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+
+	case <-ctx.Done():
+		s.mu.Lock()
+		for ws := range s.sockets {
+			ws.conn.Close()
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,231 @@
+package web
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+// Set of frame opcodes from RFC 6455 section 5.2.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// maxControlPayload is the largest payload a control frame may carry.
+const maxControlPayload = 125
+
+// Set of close status codes from RFC 6455 section 7.4.1.
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseInternalServerError     = 1011
+)
+
+// CloseError is returned when the close handshake started, by the client or
+// because the client broke the protocol.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+// Error implements the error interface.
+func (ce *CloseError) Error() string {
+	if ce.Reason == "" {
+		return fmt.Sprintf("websocket closed: %d", ce.Code)
+	}
+	return fmt.Sprintf("websocket closed: %d %s", ce.Code, ce.Reason)
+}
+
+// protocolError returns the error for a frame that breaks the protocol.
+func protocolError(reason string) error {
+	return &CloseError{Code: CloseProtocolError, Reason: reason}
+}
+
+// frame is a single frame read from the client.
+type frame struct {
+	fin     bool
+	opcode  byte
+	payload []byte
+}
+
+// readFrame reads the next frame from the client and unmasks its payload.
+// Data frames with a payload over the limit are refused before the payload
+// is read.
+func (ws *WebSocket) readFrame(limit int64) (frame, error) {
+	if ws.cfg.IdleTimeout > 0 && !ws.closing() {
+		ws.conn.SetReadDeadline(time.Now().Add(ws.cfg.IdleTimeout))
+	}
+
+	var head [2]byte
+	if _, err := io.ReadFull(ws.br, head[:]); err != nil {
+		return frame{}, err
+	}
+
+	f := frame{
+		fin:    head[0]&0x80 != 0,
+		opcode: head[0] & 0x0f,
+	}
+
+	if head[0]&0x70 != 0 {
+		return frame{}, protocolError("reserved bits are set")
+	}
+	if head[1]&0x80 == 0 {
+		return frame{}, protocolError("frames of the client must be masked")
+	}
+
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.br, ext[:]); err != nil {
+			return frame{}, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.br, ext[:]); err != nil {
+			return frame{}, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+		if length>>63 != 0 {
+			return frame{}, protocolError("invalid payload length")
+		}
+	}
+
+	if f.opcode >= opClose {
+		if !f.fin || length > maxControlPayload {
+			return frame{}, protocolError("invalid control frame")
+		}
+	} else if length > uint64(limit) {
+		return frame{}, &CloseError{Code: CloseMessageTooBig, Reason: "message too big"}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.br, mask[:]); err != nil {
+		return frame{}, err
+	}
+
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(ws.br, f.payload); err != nil {
+		return frame{}, err
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+
+	return f, nil
+}
+
+// closing reports whether the close frame was sent.
+func (ws *WebSocket) closing() bool {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+
+	return ws.closeSent
+}
+
+// writeFrame sends a single final frame to the client. Nothing but the
+// close frame itself may be written once the close frame was sent.
+func (ws *WebSocket) writeFrame(opcode byte, payload []byte) error {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+
+	if ws.closeSent {
+		return ErrWebSocketClosed
+	}
+
+	return ws.writeFrameLocked(opcode, payload)
+}
+
+// writeFrameLocked writes the frame in a single write so frames of
+// different goroutines never interleave. The caller holds the write lock.
+// Frames of the server are not masked.
+func (ws *WebSocket) writeFrameLocked(opcode byte, payload []byte) error {
+	if opcode >= opClose && len(payload) > maxControlPayload {
+		return fmt.Errorf("control frame payload of %d bytes is too big", len(payload))
+	}
+
+	buf := make([]byte, 0, 10+len(payload))
+	buf = append(buf, 0x80|opcode)
+
+	switch n := len(payload); {
+	case n <= 125:
+		buf = append(buf, byte(n))
+	case n <= 0xffff:
+		buf = append(buf, 126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, 127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+	buf = append(buf, payload...)
+
+	ws.conn.SetWriteDeadline(time.Now().Add(ws.cfg.WriteTimeout))
+	_, err := ws.conn.Write(buf)
+	return err
+}
+
+// parseClose reads the status code and reason of a close frame. A frame
+// without a payload carries no status.
+func parseClose(payload []byte) (*CloseError, error) {
+	switch {
+	case len(payload) == 0:
+		return &CloseError{Code: CloseNoStatusReceived}, nil
+	case len(payload) == 1:
+		return nil, protocolError("invalid close frame")
+	}
+
+	ce := CloseError{
+		Code:   int(binary.BigEndian.Uint16(payload)),
+		Reason: string(payload[2:]),
+	}
+
+	if !validCloseCode(ce.Code) {
+		return nil, protocolError("invalid close code")
+	}
+	if !utf8.ValidString(ce.Reason) {
+		return nil, &CloseError{Code: CloseInvalidFramePayloadData, Reason: "close reason is not valid utf-8"}
+	}
+
+	return &ce, nil
+}
+
+// closePayload builds the payload of a close frame. The reason is cut to
+// fit a control frame. The no status code is never sent on the wire.
+func closePayload(code int, reason string) []byte {
+	if code == CloseNoStatusReceived {
+		return nil
+	}
+
+	if len(reason) > maxControlPayload-2 {
+		reason = reason[:maxControlPayload-2]
+	}
+
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	return append(payload, reason...)
+}
+
+// validCloseCode reports whether the code may be sent in a close frame.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code >= 1000 && code <= 1011:
+		return code != 1004 && code != 1005 && code != 1006
+	}
+	return false
+}
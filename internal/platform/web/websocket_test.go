@@ -0,0 +1,258 @@
+package web_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/internal/platform/web"
+)
+
+func Test_WebSocket(t *testing.T) {
+	t.Log("Given the need to talk to clients over WebSockets.")
+	{
+		var trail []string
+		handlerErr := make(chan error, 1)
+
+		app := web.New(nil, nil, requestErrors, record("app", &trail))
+
+		echo := func(ctx context.Context, ws *web.WebSocket) error {
+			for {
+				typ, msg, err := ws.ReadMessage()
+				if err != nil {
+					handlerErr <- err
+					return err
+				}
+				if err := ws.WriteMessage(typ, msg); err != nil {
+					return err
+				}
+			}
+		}
+		app.Handle(http.MethodGet, "", "/echo", app.WebSocket(echo, web.WebSocketConfig{ReadLimit: 64}))
+
+		wait := func(ctx context.Context, ws *web.WebSocket) error {
+			handlerErr <- nil
+			<-ctx.Done()
+			return nil
+		}
+		app.Handle(http.MethodGet, "", "/wait", app.WebSocket(wait, web.WebSocketConfig{}))
+
+		srv := httptest.NewServer(app)
+		defer srv.Close()
+
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a client exchanges messages.", testID)
+		{
+			c, resp := dialWebSocket(t, srv, "/echo")
+			defer c.conn.Close()
+
+			if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get(web.TraceIDHeader) == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould upgrade with the trace id : got %d.", failed, testID, resp.StatusCode)
+			}
+			if strings.Join(trail, ",") != "app" {
+				t.Fatalf("\t%s\tTest %d:\tShould run the middleware before the upgrade : got %v.", failed, testID, trail)
+			}
+			t.Logf("\t%s\tTest %d:\tShould upgrade after running the middleware.", success, testID)
+
+			c.write(t, 0x1, []byte("hello"))
+			if op, payload := c.read(t); op != 0x1 || string(payload) != "hello" {
+				t.Fatalf("\t%s\tTest %d:\tShould echo the message : got %d %q.", failed, testID, op, payload)
+			}
+			t.Logf("\t%s\tTest %d:\tShould echo the message.", success, testID)
+
+			c.write(t, 0x9, []byte("ping"))
+			if op, payload := c.read(t); op != 0xa || string(payload) != "ping" {
+				t.Fatalf("\t%s\tTest %d:\tShould answer a ping : got %d %q.", failed, testID, op, payload)
+			}
+			t.Logf("\t%s\tTest %d:\tShould answer a ping.", success, testID)
+
+			c.write(t, 0x8, binary.BigEndian.AppendUint16(nil, web.CloseNormalClosure))
+			if op, payload := c.read(t); op != 0x8 || closeCode(payload) != web.CloseNormalClosure {
+				t.Fatalf("\t%s\tTest %d:\tShould answer the close frame : got %d %v.", failed, testID, op, payload)
+			}
+
+			var ce *web.CloseError
+			if err := <-handlerErr; !errors.As(err, &ce) || ce.Code != web.CloseNormalClosure {
+				t.Fatalf("\t%s\tTest %d:\tShould return the close to the handler : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould complete the close handshake.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen a client sends a message over the limit.", testID)
+		{
+			c, _ := dialWebSocket(t, srv, "/echo")
+			defer c.conn.Close()
+
+			c.write(t, 0x2, make([]byte, 65))
+			if op, payload := c.read(t); op != 0x8 || closeCode(payload) != web.CloseMessageTooBig {
+				t.Fatalf("\t%s\tTest %d:\tShould close the connection as too big : got %d %v.", failed, testID, op, payload)
+			}
+			<-handlerErr
+			t.Logf("\t%s\tTest %d:\tShould close the connection as too big.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen a request is not an upgrade.", testID)
+		{
+			resp, err := http.Get(srv.URL + "/echo")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to make the request : %v.", failed, testID, err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse the request : got %d.", failed, testID, resp.StatusCode)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse the request.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen the application shuts down.", testID)
+		{
+			c, _ := dialWebSocket(t, srv, "/wait")
+			defer c.conn.Close()
+			<-handlerErr
+
+			closed := make(chan error, 1)
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				closed <- app.CloseWebSockets(ctx)
+			}()
+
+			op, payload := c.read(t)
+			if op != 0x8 || closeCode(payload) != web.CloseGoingAway {
+				t.Fatalf("\t%s\tTest %d:\tShould send a going away close frame : got %d %v.", failed, testID, op, payload)
+			}
+			t.Logf("\t%s\tTest %d:\tShould send a going away close frame.", success, testID)
+
+			c.write(t, 0x8, payload)
+			if err := <-closed; err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould wait for the handshake to complete : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould wait for the handshake to complete.", success, testID)
+
+			_, resp := dialWebSocket(t, srv, "/wait")
+			if resp.StatusCode != http.StatusServiceUnavailable {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse new connections : got %d.", failed, testID, resp.StatusCode)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse new connections.", success, testID)
+		}
+	}
+}
+
+// =============================================================================
+
+// requestErrors turns request errors into their status like the errors
+// middleware of the service does.
+func requestErrors(handler web.Handler) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		if err := handler(ctx, w, r); err != nil {
+			status := http.StatusInternalServerError
+			if validate.IsRequestError(err) {
+				status = validate.GetRequestError(err).Status
+			}
+			return web.Respond(ctx, w, nil, status)
+		}
+		return nil
+	}
+}
+
+// wsClient is a minimal client side of the protocol.
+type wsClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// dialWebSocket performs the opening handshake against the path.
+func dialWebSocket(t *testing.T, srv *httptest.Server, path string) (*wsClient, *http.Response) {
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	key := make([]byte, 16)
+	rand.Read(key)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(key))
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("writing handshake: %v", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatalf("reading handshake: %v", err)
+	}
+
+	return &wsClient{conn: conn, br: br}, resp
+}
+
+// write sends a single masked frame.
+func (c *wsClient) write(t *testing.T, opcode byte, payload []byte) {
+	frame := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, 0x80|byte(n))
+	default:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	}
+
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatalf("writing frame: %v", err)
+	}
+}
+
+// read returns the opcode and payload of the next frame.
+func (c *wsClient) read(t *testing.T) (byte, []byte) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		t.Fatalf("reading frame: %v", err)
+	}
+
+	n := int(head[1] & 0x7f)
+	if n == 126 {
+		var ext [2]byte
+		io.ReadFull(c.br, ext[:])
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	}
+
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		t.Fatalf("reading payload: %v", err)
+	}
+
+	return head[0] & 0x0f, payload
+}
+
+// closeCode returns the status code of a close frame payload.
+func closeCode(payload []byte) int {
+	if len(payload) < 2 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint16(payload))
+}